@@ -0,0 +1,25 @@
+package timedmap
+
+import "time"
+
+// [Cache] is the set of operations shared by the maps of this package, so that code written against it
+// can switch between a [TimedMap] and a [Sharded] map without changing its call sites.
+type Cache[K comparable, V any] interface {
+	// Put adds a value and its time-to-live duration for the given key.
+	Put(key K, value V, ttl time.Duration)
+	// Get returns the value associated with the given key and a boolean indicating if the key exists and has not expired.
+	Get(key K) (V, bool)
+	// Delete removes the value associated with the given key regardless of its expiration time.
+	Delete(key K)
+	// Contains returns true if the given key exists and has not expired, false otherwise.
+	Contains(key K) bool
+	// Size returns the number of entries, including expired entries that have not been removed yet.
+	Size() int
+	// Clear removes all entries.
+	Clear()
+}
+
+var (
+	_ Cache[string, int] = (*TimedMap[string, int])(nil)
+	_ Cache[string, int] = (*Sharded[string, int])(nil)
+)
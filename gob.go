@@ -0,0 +1,61 @@
+package timedmap
+
+import (
+	"bytes"
+	"encoding/gob"
+	"time"
+)
+
+// gobEntry is the gob representation of a single entry of a [TimedMap].
+type gobEntry[K comparable, V any] struct {
+	Key   K
+	Value V
+	// TTL is the remaining time-to-live of the entry at encoding time, or [NoExpiration].
+	TTL time.Duration
+	// Permanent reports whether the entry never expires.
+	Permanent bool
+}
+
+// GobEncode implements [gob.GobEncoder]. It encodes the entries of the [TimedMap] that have not expired
+// with their remaining time-to-live, so that they can be restored relative to the time they are decoded.
+// As with any use of [encoding/gob], concrete types stored in interface keys or values must be registered with [gob.Register].
+func (tm *TimedMap[K, V]) GobEncode() ([]byte, error) {
+	items := tm.Snapshot()
+	entries := make([]gobEntry[K, V], len(items))
+	for i, item := range items {
+		entries[i] = gobEntry[K, V]{
+			Key:       item.Key,
+			Value:     item.Value,
+			TTL:       item.TTL,
+			Permanent: item.Permanent,
+		}
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(entries); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// GobDecode implements [gob.GobDecoder]. It adds the entries encoded by [TimedMap.GobEncode] to the [TimedMap]
+// like [TimedMap.Restore], recomputing their expiration time from their remaining time-to-live relative to now.
+// Existing entries with the same keys are replaced.
+// A zero [TimedMap], such as the one allocated by [encoding/gob] for a pointer field, is initialized
+// like one created by [NewLazy], without a background cleanup.
+func (tm *TimedMap[K, V]) GobDecode(data []byte) error {
+	var entries []gobEntry[K, V]
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&entries); err != nil {
+		return err
+	}
+	items := make([]Entry[K, V], len(entries))
+	for i, e := range entries {
+		items[i] = Entry[K, V]{
+			Key:       e.Key,
+			Value:     e.Value,
+			TTL:       e.TTL,
+			Permanent: e.Permanent,
+		}
+	}
+	tm.Restore(items)
+	return nil
+}
@@ -0,0 +1,61 @@
+package timedmap
+
+import (
+	"bytes"
+	"encoding/gob"
+	"testing"
+	"time"
+)
+
+type point struct {
+	X, Y int
+}
+
+func TestTimedMapGob(t *testing.T) {
+	clock := newFakeClock()
+	tm := NewWithClock[point, []string](time.Minute, clock)
+	tm.Put(point{1, 2}, []string{"a", "b"}, time.Hour)
+	tm.PutPermanent(point{3, 4}, []string{"c"})
+	tm.Put(point{5, 6}, []string{"d"}, time.Second)
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(tm); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	restoredClock := newFakeClock()
+	restoredClock.Advance(time.Minute)
+	restored := NewWithClock[point, []string](time.Minute, restoredClock)
+	if err := gob.NewDecoder(&buf).Decode(restored); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if restored.Size() != 3 {
+		t.Errorf("expected size 3, got %d", restored.Size())
+	}
+	if value, ok := restored.Get(point{1, 2}); !ok || len(value) != 2 || value[1] != "b" {
+		t.Errorf("expected value [a b], got %v", value)
+	}
+	if ttl, ok := restored.TTL(point{1, 2}); !ok || ttl != time.Hour {
+		t.Errorf("expected the remaining time-to-live to be restored relative to decoding, got %v", ttl)
+	}
+	if ttl, ok := restored.TTL(point{3, 4}); !ok || ttl != NoExpiration {
+		t.Errorf("expected permanent entry to be preserved, got ttl %v", ttl)
+	}
+}
+
+func TestTimedMapGobStructField(t *testing.T) {
+	tm := New[string, int](0)
+	tm.Put("key", 19, time.Hour)
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(struct{ M *TimedMap[string, int] }{tm}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var restored struct{ M *TimedMap[string, int] }
+	if err := gob.NewDecoder(&buf).Decode(&restored); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if value, ok := restored.M.Get("key"); !ok || value != 19 {
+		t.Errorf("expected value 19, got %d", value)
+	}
+	if ttl, ok := restored.M.TTL("key"); !ok || ttl <= 59*time.Minute {
+		t.Errorf("expected expiration to be preserved, got ttl %v", ttl)
+	}
+}
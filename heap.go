@@ -0,0 +1,90 @@
+package timedmap
+
+import "container/heap"
+
+// expirationHeap is a min-heap of entries ordered by their expiration time, implementing [container/heap.Interface].
+// It lets the cleanup find expired entries without scanning the whole store. Entries that never expire are not part of it.
+type expirationHeap[K comparable, V any] []*entry[K, V]
+
+func (h expirationHeap[K, V]) Len() int {
+	return len(h)
+}
+
+func (h expirationHeap[K, V]) Less(i, j int) bool {
+	return h[i].expiration.Before(h[j].expiration)
+}
+
+func (h expirationHeap[K, V]) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *expirationHeap[K, V]) Push(x any) {
+	e := x.(*entry[K, V])
+	e.index = len(*h)
+	*h = append(*h, e)
+}
+
+func (h *expirationHeap[K, V]) Pop() any {
+	old := *h
+	n := len(old)
+	e := old[n-1]
+	old[n-1] = nil
+	e.index = -1
+	*h = old[:n-1]
+	return e
+}
+
+// earliest returns, in ascending order of expiration time, up to n entries of the heap for which keep returns true.
+// It does not modify the heap: it walks it from the root, visiting only the entries it considers and their children,
+// so that the cost depends on n rather than on the size of the heap.
+func (h expirationHeap[K, V]) earliest(n int, keep func(e *entry[K, V]) bool) []*entry[K, V] {
+	var entries []*entry[K, V]
+	f := &frontier[K, V]{heap: h}
+	if len(h) > 0 {
+		f.indices = []int{0}
+	}
+	for f.Len() > 0 && len(entries) < n {
+		i := heap.Pop(f).(int)
+		if keep(h[i]) {
+			entries = append(entries, h[i])
+		}
+		for _, child := range [...]int{2*i + 1, 2*i + 2} {
+			if child < len(h) {
+				heap.Push(f, child)
+			}
+		}
+	}
+	return entries
+}
+
+// frontier is a min-heap of positions in an [expirationHeap], ordered by the expiration time of their entries.
+// It holds the positions left to visit by earliest.
+type frontier[K comparable, V any] struct {
+	heap    expirationHeap[K, V]
+	indices []int
+}
+
+func (f *frontier[K, V]) Len() int {
+	return len(f.indices)
+}
+
+func (f *frontier[K, V]) Less(i, j int) bool {
+	return f.heap.Less(f.indices[i], f.indices[j])
+}
+
+func (f *frontier[K, V]) Swap(i, j int) {
+	f.indices[i], f.indices[j] = f.indices[j], f.indices[i]
+}
+
+func (f *frontier[K, V]) Push(x any) {
+	f.indices = append(f.indices, x.(int))
+}
+
+func (f *frontier[K, V]) Pop() any {
+	n := len(f.indices)
+	i := f.indices[n-1]
+	f.indices = f.indices[:n-1]
+	return i
+}
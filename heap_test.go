@@ -0,0 +1,51 @@
+package timedmap
+
+import (
+	"math/rand/v2"
+	"testing"
+	"time"
+)
+
+func TestExpirationHeapCleanup(t *testing.T) {
+	clock := newFakeClock()
+	tm := NewWithClock[string, int](time.Millisecond, clock)
+	defer tm.Stop()
+	tm.Put("key1", 19, 10*time.Millisecond)
+	tm.Put("key2", 23, 10*time.Millisecond)
+	tm.Put("key3", 29, time.Hour)
+	tm.PutPermanent("permanent", 31)
+	tm.Refresh("key2", time.Hour)
+	tm.Delete("key3")
+	clock.Advance(10 * time.Millisecond)
+	eventually(func() bool { return tm.Size() == 2 })
+	tm.mu.RLock()
+	defer tm.mu.RUnlock()
+	if len(tm.store) != 2 {
+		t.Errorf("expected key2 and permanent to remain, got size %d", len(tm.store))
+	}
+	if len(tm.queue) != 1 || tm.queue[0].key != "key2" || tm.queue[0].index != 0 {
+		t.Errorf("expected only key2 in the expiration heap, got %d entries", len(tm.queue))
+	}
+}
+
+func TestExpirationHeapEarliest(t *testing.T) {
+	clock := newFakeClock()
+	tm := NewWithClock[int, int](0, clock)
+	for _, i := range rand.Perm(100) {
+		tm.Put(i, i, time.Duration(i)*time.Second)
+	}
+	tm.PutPermanent(-1, -1)
+	clock.Advance(10 * time.Second)
+	items := tm.ExpiringSoon(5)
+	if len(items) != 5 {
+		t.Fatalf("expected 5 entries, got %d", len(items))
+	}
+	for i, item := range items {
+		if item.Key != 11+i || item.TTL != time.Duration(1+i)*time.Second {
+			t.Errorf("expected key %d at position %d with a TTL of %ds, got key %d with %v", 11+i, i, 1+i, item.Key, item.TTL)
+		}
+	}
+	if items := tm.ExpiringSoon(1000); len(items) != 89 {
+		t.Errorf("expected the 89 entries that expire, got %d", len(items))
+	}
+}
@@ -0,0 +1,59 @@
+package timedmap
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// jsonEntry is the JSON representation of a single entry of a [TimedMap].
+type jsonEntry[K comparable, V any] struct {
+	Key   K `json:"key"`
+	Value V `json:"value"`
+	// Expiration is omitted for entries that never expire.
+	Expiration *time.Time `json:"expiration,omitempty"`
+}
+
+// MarshalJSON implements [json.Marshaler]. It encodes the entries of the [TimedMap] that have not expired
+// as a JSON array of objects holding the key, the value and the expiration time of each entry.
+// Since keys are encoded as array elements rather than object keys, any key type supported by
+// [encoding/json] can be used.
+func (tm *TimedMap[K, V]) MarshalJSON() ([]byte, error) {
+	items := tm.Items()
+	entries := make([]jsonEntry[K, V], len(items))
+	for i, item := range items {
+		entries[i] = jsonEntry[K, V]{
+			Key:   item.Key,
+			Value: item.Value,
+		}
+		if !item.Expiration.IsZero() {
+			entries[i].Expiration = &item.Expiration
+		}
+	}
+	return json.Marshal(entries)
+}
+
+// UnmarshalJSON implements [json.Unmarshaler]. It adds the entries encoded by [TimedMap.MarshalJSON]
+// to the [TimedMap], skipping entries that have already expired. Existing entries with the same keys are replaced.
+// A zero [TimedMap], such as the one allocated by [encoding/json] for a pointer field, is initialized
+// like one created by [NewLazy], without a background cleanup.
+func (tm *TimedMap[K, V]) UnmarshalJSON(data []byte) error {
+	var entries []jsonEntry[K, V]
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return err
+	}
+	tm.mu.Lock()
+	defer tm.unlock()
+	tm.initialize()
+	now := tm.clock.Now()
+	for _, e := range entries {
+		var expiration time.Time
+		if e.Expiration != nil {
+			if !now.Before(*e.Expiration) {
+				continue
+			}
+			expiration = *e.Expiration
+		}
+		tm.set(e.Key, e.Value, expiration)
+	}
+	return nil
+}
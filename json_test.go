@@ -0,0 +1,66 @@
+package timedmap
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestTimedMapJSON(t *testing.T) {
+	tm := New[string, int](time.Minute)
+	tm.Put("key", 19, time.Hour)
+	tm.PutPermanent("permanent", 23)
+	tm.Put("expired", 29, -time.Second)
+	data, err := json.Marshal(tm)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	restored := New[string, int](time.Minute)
+	if err := json.Unmarshal(data, restored); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if restored.Size() != 2 {
+		t.Errorf("expected size 2, got %d", restored.Size())
+	}
+	if value, ok := restored.Get("key"); !ok || value != 19 {
+		t.Errorf("expected value 19, got %d", value)
+	}
+	if ttl, ok := restored.TTL("key"); !ok || ttl <= 59*time.Minute {
+		t.Errorf("expected expiration to be preserved, got ttl %v", ttl)
+	}
+	if ttl, ok := restored.TTL("permanent"); !ok || ttl != NoExpiration {
+		t.Errorf("expected permanent entry to be preserved, got ttl %v", ttl)
+	}
+}
+
+func TestTimedMapUnmarshalJSONSkipsExpired(t *testing.T) {
+	tm := New[int, string](time.Minute)
+	data := `[{"key":1,"value":"a","expiration":"2000-01-01T00:00:00Z"},{"key":2,"value":"b"}]`
+	if err := json.Unmarshal([]byte(data), tm); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if tm.Contains(1) || !tm.Contains(2) {
+		t.Errorf("expected only key 2 to be restored")
+	}
+}
+
+func TestTimedMapUnmarshalJSONStructField(t *testing.T) {
+	tm := New[string, int](0)
+	tm.Put("key", 19, time.Hour)
+	data, err := json.Marshal(struct{ M *TimedMap[string, int] }{tm})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var restored struct{ M *TimedMap[string, int] }
+	if err := json.Unmarshal(data, &restored); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if value, ok := restored.M.Get("key"); !ok || value != 19 {
+		t.Errorf("expected value 19, got %d", value)
+	}
+	restored.M.Put("other", 23, time.Minute)
+	restored.M.Stop()
+	if restored.M.Size() != 2 {
+		t.Errorf("expected the decoded map to be usable, got size %d", restored.M.Size())
+	}
+}
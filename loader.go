@@ -0,0 +1,182 @@
+package timedmap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrNoLoader is returned by [TimedMap.Load] if no loader has been set with [TimedMap.SetLoader].
+var ErrNoLoader = errors.New("timedmap: no loader")
+
+// call is a computation of the value of a key in progress, shared by all the callers that missed the key meanwhile.
+type call[V any] struct {
+	// done is closed once value and err have been set.
+	done  chan struct{}
+	value V
+	err   error
+}
+
+// SetLoader sets the function used by [TimedMap.Load] to fetch the value of a key that does not exist or has expired,
+// along with the time-to-live duration to store it with. Passing nil removes the loader.
+func (tm *TimedMap[K, V]) SetLoader(loader func(key K) (V, time.Duration, error)) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	tm.loader = loader
+}
+
+// Load returns the value associated with the given key if it exists and has not expired. Otherwise, it calls
+// the loader set with [TimedMap.SetLoader], stores the value it returns with the time-to-live duration it returns,
+// and returns the value. If the loader fails, its error is returned and nothing is stored.
+// Concurrent calls for the same key share a single call to the loader, which is made without holding the lock.
+// It returns [ErrNoLoader] if no loader has been set, and [ErrNotFound] without calling the loader
+// if the key is known to be absent because of [TimedMap.PutTombstone].
+func (tm *TimedMap[K, V]) Load(key K) (V, error) {
+	tm.mu.RLock()
+	loader := tm.loader
+	tm.mu.RUnlock()
+	if loader == nil {
+		var zero V
+		return zero, ErrNoLoader
+	}
+	return tm.compute(key, func() (V, time.Duration, error) {
+		return loader(key)
+	})
+}
+
+// GetOrComputeOnce returns the existing value for the given key if it exists and has not expired.
+// Otherwise, it calls f without holding the lock, adds its result with the given time-to-live duration and returns it.
+// If f fails, its error is returned and nothing is stored. Unlike [TimedMap.GetOrCompute], concurrent callers
+// that miss the same key share a single call to f, or to the loader of a concurrent [TimedMap.Load],
+// the others waiting for its result. Like Load, it returns [ErrNotFound] without calling f for a tombstone.
+func (tm *TimedMap[K, V]) GetOrComputeOnce(key K, ttl time.Duration, f func() (V, error)) (V, error) {
+	return tm.compute(key, func() (V, time.Duration, error) {
+		value, err := f()
+		return value, ttl, err
+	})
+}
+
+// WarmUp loads the given keys with loader, running at most concurrency calls at once, and stores each value
+// with the time-to-live duration it returns, so that the [TimedMap] is populated before serving traffic.
+// Keys that already hold a value or a tombstone that has not expired are left untouched, and concurrent callers missing the same key
+// share the call to loader as with [TimedMap.Load]. If concurrency is zero or less, the keys are loaded one at a time.
+// Once ctx is done, no more keys are loaded and WarmUp returns after the calls in progress have completed.
+// The returned error joins the errors of the failed keys, a [*CallbackError] for a loader that panicked, and ctx.Err()
+// if ctx was done before every key was loaded. The keys loaded successfully are stored even if others failed.
+func (tm *TimedMap[K, V]) WarmUp(ctx context.Context, keys []K, loader func(key K) (V, time.Duration, error), concurrency int) error {
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+	sem := make(chan struct{}, max(concurrency, 1))
+	for _, key := range keys {
+		if ctx.Err() == nil {
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			mu.Lock()
+			errs = append(errs, err)
+			mu.Unlock()
+			break
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if err := tm.warm(key, loader); err != nil {
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("timedmap: loading %v: %w", key, err))
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// warm loads the given key for WarmUp, turning a panic of loader into a [*CallbackError].
+func (tm *TimedMap[K, V]) warm(key K, loader func(key K) (V, time.Duration, error)) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &CallbackError{Value: r}
+		}
+	}()
+	loaded := false
+	_, err = tm.compute(key, func() (V, time.Duration, error) {
+		loaded = true
+		return loader(key)
+	})
+	if !loaded && errors.Is(err, ErrNotFound) {
+		// The key holds a tombstone, which is left in place.
+		return nil
+	}
+	return err
+}
+
+// compute returns the value associated with the given key if it exists and has not expired.
+// Otherwise, it calls f without holding the lock, unless a call for the same key is already in progress,
+// in which case it waits for its result. On success, the value returned by f is stored with the time-to-live duration it returns.
+// If f panics, the callers waiting for it receive a [*CallbackError] and the panic is propagated to the caller of f.
+// For a key holding a tombstone that has not expired, it returns [ErrNotFound] without calling f.
+func (tm *TimedMap[K, V]) compute(key K, f func() (V, time.Duration, error)) (V, error) {
+	tm.mu.Lock()
+	now := tm.clock.Now()
+	if e, ok := tm.store[key]; ok {
+		if e.live(now) {
+			tm.touch(e)
+			value := e.value
+			tm.unlock()
+			tm.hits.Add(1)
+			return value, nil
+		}
+		if e.expired(now) {
+			tm.expire(e)
+		} else {
+			// A live tombstone records that the key has no value, which is what the caller would load.
+			tm.unlock()
+			tm.misses.Add(1)
+			var zero V
+			return zero, ErrNotFound
+		}
+	}
+	tm.misses.Add(1)
+	if c, ok := tm.calls[key]; ok {
+		tm.unlock()
+		<-c.done
+		return c.value, c.err
+	}
+	c := &call[V]{done: make(chan struct{})}
+	if tm.calls == nil {
+		tm.calls = make(map[K]*call[V])
+	}
+	tm.calls[key] = c
+	tm.unlock()
+	var ttl time.Duration
+	panicked := true
+	defer func() {
+		var r any
+		if panicked {
+			r = recover()
+			c.err = &CallbackError{Value: r}
+		}
+		tm.mu.Lock()
+		delete(tm.calls, key)
+		if c.err == nil {
+			tm.set(key, c.value, tm.expiresAt(tm.clock.Now(), ttl))
+		}
+		close(c.done)
+		tm.unlock()
+		if panicked {
+			panic(r)
+		}
+	}()
+	c.value, ttl, c.err = f()
+	panicked = false
+	return c.value, c.err
+}
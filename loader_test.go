@@ -0,0 +1,172 @@
+package timedmap
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTimedMapLoad(t *testing.T) {
+	tm := New[string, int](time.Minute)
+	defer tm.Stop()
+	if _, err := tm.Load("key"); !errors.Is(err, ErrNoLoader) {
+		t.Errorf("expected ErrNoLoader, got %v", err)
+	}
+	var loads atomic.Int32
+	release := make(chan struct{})
+	tm.SetLoader(func(key string) (int, time.Duration, error) {
+		loads.Add(1)
+		<-release
+		return len(key), time.Minute, nil
+	})
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if value, err := tm.Load("key"); err != nil || value != 3 {
+				t.Errorf("expected (3, nil), got (%d, %v)", value, err)
+			}
+		}()
+	}
+	// Every caller misses the key while the first load is blocked, so ten misses mean that they all wait for it.
+	if !eventually(func() bool { return tm.Stats().Misses == 10 }) {
+		t.Errorf("expected every caller to miss the key, got %d misses", tm.Stats().Misses)
+	}
+	close(release)
+	wg.Wait()
+	if loads.Load() != 1 {
+		t.Errorf("expected concurrent loads to share a single call, got %d calls", loads.Load())
+	}
+	if value, ok := tm.Get("key"); !ok || value != 3 {
+		t.Errorf("expected loaded value to be stored, got %d", value)
+	}
+}
+
+func TestTimedMapLoadError(t *testing.T) {
+	tm := New[string, int](time.Minute)
+	defer tm.Stop()
+	errLoad := errors.New("load failed")
+	tm.SetLoader(func(key string) (int, time.Duration, error) {
+		return 0, time.Minute, errLoad
+	})
+	if _, err := tm.Load("key"); !errors.Is(err, errLoad) {
+		t.Errorf("expected the loader error, got %v", err)
+	}
+	if tm.Contains("key") {
+		t.Errorf("expected nothing to be stored on error")
+	}
+}
+
+func TestTimedMapGetOrComputeOnce(t *testing.T) {
+	tm := New[string, int](time.Minute)
+	defer tm.Stop()
+	var computations atomic.Int32
+	release := make(chan struct{})
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			value, err := tm.GetOrComputeOnce("key", time.Minute, func() (int, error) {
+				computations.Add(1)
+				<-release
+				return 19, nil
+			})
+			if err != nil || value != 19 {
+				t.Errorf("expected (19, nil), got (%d, %v)", value, err)
+			}
+		}()
+	}
+	// Every caller misses the key while the first computation is blocked, so ten misses mean that they all wait for it.
+	if !eventually(func() bool { return tm.Stats().Misses == 10 }) {
+		t.Errorf("expected every caller to miss the key, got %d misses", tm.Stats().Misses)
+	}
+	close(release)
+	wg.Wait()
+	if computations.Load() != 1 {
+		t.Errorf("expected concurrent callers to share a single computation, got %d", computations.Load())
+	}
+	defer func() {
+		if r := recover(); r != "boom" {
+			t.Errorf("expected the panic to be propagated, got %v", r)
+		}
+		if _, ok := tm.calls["panic"]; ok {
+			t.Errorf("expected the computation to be released after a panic")
+		}
+	}()
+	tm.GetOrComputeOnce("panic", time.Minute, func() (int, error) {
+		panic("boom")
+	})
+}
+
+func TestTimedMapWarmUp(t *testing.T) {
+	tm := New[int, int](0)
+	tm.Put(0, -1, time.Minute)
+	errLoad := errors.New("load failed")
+	var running, peak atomic.Int32
+	loader := func(key int) (int, time.Duration, error) {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for p := peak.Load(); n > p && !peak.CompareAndSwap(p, n); p = peak.Load() {
+		}
+		time.Sleep(time.Millisecond)
+		if key == 7 {
+			return 0, time.Minute, errLoad
+		}
+		return key * 2, time.Minute, nil
+	}
+	keys := []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}
+	if err := tm.WarmUp(context.Background(), keys, loader, 3); !errors.Is(err, errLoad) {
+		t.Errorf("expected the loader error, got %v", err)
+	}
+	if peak.Load() > 3 {
+		t.Errorf("expected at most 3 concurrent loads, got %d", peak.Load())
+	}
+	if value, _ := tm.Get(0); value != -1 {
+		t.Errorf("expected the existing key to be left untouched, got %d", value)
+	}
+	if tm.Size() != 9 || tm.Contains(7) {
+		t.Errorf("expected every key but the failed one to be stored, got size %d", tm.Size())
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := tm.WarmUp(ctx, []int{10, 11}, loader, 1); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	if tm.Contains(10) || tm.Contains(11) {
+		t.Errorf("expected no key to be loaded once the context is done")
+	}
+}
+
+func TestTimedMapLoadTombstone(t *testing.T) {
+	tm := New[string, int](0)
+	var loads atomic.Int32
+	loader := func(key string) (int, time.Duration, error) {
+		loads.Add(1)
+		return 19, time.Minute, nil
+	}
+	tm.SetLoader(loader)
+	tm.PutTombstone("key", time.Minute)
+	if _, err := tm.Load("key"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound for a tombstone, got %v", err)
+	}
+	if _, err := tm.GetOrComputeOnce("key", time.Minute, func() (int, error) {
+		loads.Add(1)
+		return 19, nil
+	}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound for a tombstone, got %v", err)
+	}
+	if err := tm.WarmUp(context.Background(), []string{"key"}, loader, 1); err != nil {
+		t.Errorf("expected WarmUp to skip the tombstone, got %v", err)
+	}
+	if loads.Load() != 0 {
+		t.Errorf("expected the tombstone to prevent loading, got %d loads", loads.Load())
+	}
+	if _, state := tm.GetWithState("key"); state != NegativeCached {
+		t.Errorf("expected the tombstone to remain, got %v", state)
+	}
+}
@@ -0,0 +1,30 @@
+package timedmap
+
+import "time"
+
+// Number is a constraint that permits any integer or floating-point type.
+type Number interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 |
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr |
+		~float32 | ~float64
+}
+
+// Increment adds delta to the value of the given key, treating a key that does not exist or has expired as zero,
+// stores the result with the given time-to-live duration and returns it. The whole operation happens under the write lock,
+// so concurrent increments of the same key are never lost.
+func Increment[K comparable, V Number](tm *TimedMap[K, V], key K, delta V, ttl time.Duration) V {
+	tm.mu.Lock()
+	defer tm.unlock()
+	now := tm.clock.Now()
+	var value V
+	if e, ok := tm.store[key]; ok {
+		if e.expired(now) {
+			tm.expire(e)
+		} else if !e.tombstone {
+			value = e.value
+		}
+	}
+	value += delta
+	tm.set(key, value, tm.expiresAt(now, ttl))
+	return value
+}
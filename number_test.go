@@ -0,0 +1,34 @@
+package timedmap
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestIncrement(t *testing.T) {
+	tm := New[string, int](time.Minute)
+	defer tm.Stop()
+	var wg sync.WaitGroup
+	for range 100 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			Increment(tm, "counter", 2, time.Minute)
+		}()
+	}
+	wg.Wait()
+	if value, _ := tm.Get("counter"); value != 200 {
+		t.Errorf("expected value 200, got %d", value)
+	}
+	tm.Put("expired", 19, -time.Second)
+	if value := Increment(tm, "expired", 1, time.Minute); value != 1 {
+		t.Errorf("expected expired value to count as zero, got %d", value)
+	}
+	floats := New[string, float64](time.Minute)
+	defer floats.Stop()
+	Increment(floats, "total", 0.5, time.Minute)
+	if value := Increment(floats, "total", 0.25, time.Minute); value != 0.75 {
+		t.Errorf("expected value 0.75, got %v", value)
+	}
+}
@@ -0,0 +1,150 @@
+package timedmap
+
+import (
+	"container/list"
+	"context"
+	"math/rand/v2"
+	"time"
+)
+
+// DefaultCleanupInterval is the cleanup interval of a [TimedMap] created by [NewWithOptions] without [WithCleanupInterval].
+const DefaultCleanupInterval = time.Minute
+
+// [Option] configures a [TimedMap] created by [NewWithOptions].
+type Option[K comparable, V any] func(tm *TimedMap[K, V])
+
+// NewWithOptions creates a new [TimedMap] configured by the given options, applied in order.
+// Unless [WithCleanupInterval] is given, the cleanup interval is [DefaultCleanupInterval].
+// The entries given with [WithInitialEntries] are added once all the options have been applied.
+func NewWithOptions[K comparable, V any](opts ...Option[K, V]) *TimedMap[K, V] {
+	tm := newTimedMap[K, V](DefaultCleanupInterval)
+	for _, opt := range opts {
+		opt(tm)
+	}
+	if tm.initial != nil {
+		tm.Restore(tm.initial)
+		tm.initial = nil
+	}
+	tm.start()
+	return tm
+}
+
+// WithCleanupInterval sets the interval of the background cleanup, as for [New].
+// If the interval is zero or less, no background cleanup goroutine is started.
+func WithCleanupInterval[K comparable, V any](interval time.Duration) Option[K, V] {
+	return func(tm *TimedMap[K, V]) {
+		tm.i = interval
+	}
+}
+
+// WithClock sets the [Clock] the [TimedMap] reads the current time from, as for [NewWithClock].
+func WithClock[K comparable, V any](clock Clock) Option[K, V] {
+	return func(tm *TimedMap[K, V]) {
+		tm.clock = clock
+	}
+}
+
+// WithCapacity bounds the number of entries to maxEntries, as for [NewWithCapacity].
+// If maxEntries is zero or less, the number of entries is unbounded.
+func WithCapacity[K comparable, V any](maxEntries int) Option[K, V] {
+	return func(tm *TimedMap[K, V]) {
+		if maxEntries > 0 {
+			tm.capacity = maxEntries
+			tm.lru = list.New()
+		}
+	}
+}
+
+// WithWeigher bounds the total weight of the entries, as measured by weigher, to maxWeight, as for [NewWithWeigher].
+// If maxWeight is zero or less or weigher is nil, the total weight is unbounded.
+func WithWeigher[K comparable, V any](maxWeight int64, weigher func(key K, value V) int64) Option[K, V] {
+	return func(tm *TimedMap[K, V]) {
+		if maxWeight > 0 && weigher != nil {
+			tm.weigher = weigher
+			tm.maxWeight = maxWeight
+			tm.lru = list.New()
+		}
+	}
+}
+
+// WithCapacityHint preallocates room for about hint entries, as for [NewWithCapacityHint].
+func WithCapacityHint[K comparable, V any](hint int) Option[K, V] {
+	return func(tm *TimedMap[K, V]) {
+		if hint > 0 {
+			tm.store = make(map[K]*entry[K, V], hint)
+			tm.queue = make(expirationHeap[K, V], 0, hint)
+		}
+	}
+}
+
+// WithDefaultTTL sets the default time-to-live used by [TimedMap.PutDefault], as for [NewWithDefaultTTL].
+func WithDefaultTTL[K comparable, V any](defaultTTL time.Duration) Option[K, V] {
+	return func(tm *TimedMap[K, V]) {
+		tm.ttl = defaultTTL
+	}
+}
+
+// WithExpirationPolicy sets the [ExpirationPolicy], as for [NewWithExpirationPolicy].
+func WithExpirationPolicy[K comparable, V any](policy ExpirationPolicy) Option[K, V] {
+	return func(tm *TimedMap[K, V]) {
+		tm.policy = policy
+	}
+}
+
+// WithContext stops the background cleanup when ctx is done, as for [NewWithContext].
+func WithContext[K comparable, V any](ctx context.Context) Option[K, V] {
+	return func(tm *TimedMap[K, V]) {
+		context.AfterFunc(ctx, tm.Stop)
+	}
+}
+
+// WithExpirationJitter randomly shortens the time-to-live of added entries, as set by [TimedMap.SetExpirationJitter].
+func WithExpirationJitter[K comparable, V any](fraction float64, src rand.Source) Option[K, V] {
+	return func(tm *TimedMap[K, V]) {
+		tm.SetExpirationJitter(fraction, src)
+	}
+}
+
+// WithOnExpire registers a callback for expired entries, as [TimedMap.OnExpire] does.
+func WithOnExpire[K comparable, V any](f func(key K, value V)) Option[K, V] {
+	return func(tm *TimedMap[K, V]) {
+		tm.hooks.onExpire = f
+	}
+}
+
+// WithOnEvict registers a callback for evicted entries, as [TimedMap.OnEvict] does.
+func WithOnEvict[K comparable, V any](f func(key K, value V)) Option[K, V] {
+	return func(tm *TimedMap[K, V]) {
+		tm.hooks.onEvict = f
+	}
+}
+
+// WithOnSweep registers a callback for the entries removed by each cleanup pass, as [TimedMap.OnSweep] does.
+func WithOnSweep[K comparable, V any](f func(removed []Entry[K, V])) Option[K, V] {
+	return func(tm *TimedMap[K, V]) {
+		tm.hooks.onSweep = f
+	}
+}
+
+// WithOnError registers a handler for panics recovered from the callbacks, as [TimedMap.OnError] does.
+func WithOnError[K comparable, V any](f func(err error)) Option[K, V] {
+	return func(tm *TimedMap[K, V]) {
+		tm.hooks.onError = f
+	}
+}
+
+// WithLoader sets the loader used by [TimedMap.Load], as [TimedMap.SetLoader] does.
+func WithLoader[K comparable, V any](loader func(key K) (V, time.Duration, error)) Option[K, V] {
+	return func(tm *TimedMap[K, V]) {
+		tm.loader = loader
+	}
+}
+
+// WithInitialEntries seeds the [TimedMap] with the given entries, as [TimedMap.Restore] does, before the background
+// cleanup starts. They are added after all the other options have been applied, so that the clock, the capacity
+// and the callbacks given in any order apply to them. Entries that have already expired are dropped.
+func WithInitialEntries[K comparable, V any](entries []Entry[K, V]) Option[K, V] {
+	return func(tm *TimedMap[K, V]) {
+		tm.initial = append(tm.initial, entries...)
+	}
+}
@@ -0,0 +1,69 @@
+package timedmap
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewWithOptions(t *testing.T) {
+	clock := newFakeClock()
+	var evicted []string
+	tm := NewWithOptions(
+		WithCleanupInterval[string, int](0),
+		WithClock[string, int](clock),
+		WithCapacity[string, int](2),
+		WithDefaultTTL[string, int](time.Second),
+		WithOnEvict(func(key string, value int) {
+			evicted = append(evicted, key)
+		}),
+	)
+	if tm.CleanupInterval() != 0 || tm.t != nil {
+		t.Errorf("expected no background cleanup")
+	}
+	tm.PutDefault("key1", 19)
+	tm.PutDefault("key2", 23)
+	tm.PutDefault("key3", 29)
+	if len(evicted) != 1 || evicted[0] != "key1" {
+		t.Errorf("expected key1 to be evicted, got %v", evicted)
+	}
+	clock.Advance(time.Second)
+	if _, ok := tm.Get("key2"); ok {
+		t.Errorf("expected key2 to expire after the default TTL")
+	}
+	wrapped := NewWithCleanupInterval[string, int](time.Hour)
+	defer wrapped.Stop()
+	if interval := wrapped.CleanupInterval(); interval != time.Hour || wrapped.t == nil {
+		t.Errorf("expected a background cleanup every hour, got %v", interval)
+	}
+	defaults := NewWithOptions[string, int]()
+	defer defaults.Stop()
+	if interval := defaults.CleanupInterval(); interval != DefaultCleanupInterval {
+		t.Errorf("expected the default cleanup interval, got %v", interval)
+	}
+}
+
+func TestWithInitialEntries(t *testing.T) {
+	clock := newFakeClock()
+	tm := NewWithOptions(
+		WithInitialEntries([]Entry[string, int]{
+			{Key: "key1", Value: 19, TTL: time.Second},
+			{Key: "key2", Value: 23, TTL: 0},
+			{Key: "key3", Value: 29, TTL: NoExpiration, Permanent: true},
+		}),
+		WithCleanupInterval[string, int](0),
+		WithClock[string, int](clock),
+	)
+	if tm.Size() != 2 || tm.Contains("key2") {
+		t.Errorf("expected the expired key2 to be dropped, got size %d", tm.Size())
+	}
+	if ttl, ok := tm.TTL("key1"); !ok || ttl != time.Second {
+		t.Errorf("expected key1 to expire relative to the given clock, got %v", ttl)
+	}
+	clock.Advance(time.Second)
+	if _, ok := tm.Get("key1"); ok {
+		t.Errorf("expected key1 to expire")
+	}
+	if value, ok := tm.Get("key3"); !ok || value != 29 {
+		t.Errorf("expected the permanent key3 to remain, got %d", value)
+	}
+}
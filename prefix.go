@@ -0,0 +1,28 @@
+package timedmap
+
+import "strings"
+
+// DeletePrefix removes the entries of the given [TimedMap] whose keys start with prefix, such as all the keys
+// of a namespace, and returns the number of entries removed that had not expired. Matching entries that have expired
+// are removed as well and passed to the callback registered with [TimedMap.OnExpire].
+// All keys are scanned under a single write lock, so the removal is atomic.
+func DeletePrefix[V any](tm *TimedMap[string, V], prefix string) int {
+	tm.mu.Lock()
+	defer tm.unlock()
+	now := tm.clock.Now()
+	removed := 0
+	for key, e := range tm.store {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		if e.expired(now) {
+			tm.expire(e)
+			continue
+		}
+		tm.remove(e)
+		if !e.tombstone {
+			removed++
+		}
+	}
+	return removed
+}
@@ -0,0 +1,21 @@
+package timedmap
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDeletePrefix(t *testing.T) {
+	tm := New[string, int](time.Minute)
+	defer tm.Stop()
+	tm.Put("user:123:profile", 19, time.Minute)
+	tm.Put("user:123:settings", 23, time.Minute)
+	tm.Put("user:1234:profile", 29, time.Minute)
+	tm.Put("user:123:expired", 31, -time.Second)
+	if removed := DeletePrefix(tm, "user:123:"); removed != 2 {
+		t.Errorf("expected 2 entries to be removed, got %d", removed)
+	}
+	if tm.Size() != 1 || !tm.Contains("user:1234:profile") {
+		t.Errorf("expected only user:1234:profile to remain, got size %d", tm.Size())
+	}
+}
@@ -0,0 +1,193 @@
+package timedmap
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/maphash"
+	"math"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// [Sharded] is a map that automatically removes entries that have expired, like [TimedMap],
+// but splits its entries across several independently locked shards to reduce lock contention.
+// Operations on keys that belong to different shards do not contend with each other.
+type Sharded[K comparable, V any] struct {
+	shards []*TimedMap[K, V]
+	seed   maphash.Seed
+	t      *time.Ticker
+	done   chan struct{}
+	stop   sync.Once
+}
+
+// NewSharded creates a new [Sharded] map with the given cleanup interval and number of shards.
+// If shards is less than 1, a single shard is used. A single goroutine sweeps all shards,
+// unless the interval is zero or less, in which case expired entries are only removed when they are accessed.
+func NewSharded[K comparable, V any](interval time.Duration, shards int) *Sharded[K, V] {
+	sm := &Sharded[K, V]{
+		shards: make([]*TimedMap[K, V], max(shards, 1)),
+		seed:   maphash.MakeSeed(),
+		done:   make(chan struct{}),
+	}
+	for i := range sm.shards {
+		sm.shards[i] = newTimedMap[K, V](0)
+	}
+	if interval > 0 {
+		sm.t = time.NewTicker(interval)
+		go sm.cleanup()
+	}
+	return sm
+}
+
+// Put adds a value and its time-to-live duration to the [Sharded] map for the given key.
+func (sm *Sharded[K, V]) Put(key K, value V, ttl time.Duration) {
+	sm.shard(key).Put(key, value, ttl)
+}
+
+// Get returns the value associated with the given key and a boolean indicating if the key exists.
+// It behaves like [TimedMap.Get].
+func (sm *Sharded[K, V]) Get(key K) (V, bool) {
+	return sm.shard(key).Get(key)
+}
+
+// Contains returns true if the [Sharded] map contains the given key and it has not expired, false otherwise.
+func (sm *Sharded[K, V]) Contains(key K) bool {
+	return sm.shard(key).Contains(key)
+}
+
+// Delete removes the value associated with the given key regardless of its expiration time.
+func (sm *Sharded[K, V]) Delete(key K) {
+	sm.shard(key).Delete(key)
+}
+
+// Clear removes all entries from the [Sharded] map. Shards are cleared one after another.
+func (sm *Sharded[K, V]) Clear() {
+	for _, tm := range sm.shards {
+		tm.Clear()
+	}
+}
+
+// Size returns the number of entries in the [Sharded] map, summed across all shards.
+func (sm *Sharded[K, V]) Size() int {
+	size := 0
+	for _, tm := range sm.shards {
+		size += tm.Size()
+	}
+	return size
+}
+
+// Stop terminates the background cleanup goroutine of the [Sharded] map. It is safe to call Stop more than once.
+func (sm *Sharded[K, V]) Stop() {
+	sm.stop.Do(func() {
+		close(sm.done)
+	})
+}
+
+// shard returns the shard responsible for the given key.
+func (sm *Sharded[K, V]) shard(key K) *TimedMap[K, V] {
+	return sm.shards[hashKey(sm.seed, key)%uint64(len(sm.shards))]
+}
+
+// cleanup periodically removes expired entries from all shards. It runs in a separate goroutine until [Sharded.Stop] is called.
+func (sm *Sharded[K, V]) cleanup() {
+	defer sm.t.Stop()
+	for {
+		select {
+		case <-sm.t.C:
+			for _, tm := range sm.shards {
+				tm.sweep()
+			}
+		case <-sm.done:
+			return
+		}
+	}
+}
+
+// hashKey hashes the given key with the given seed. Strings, integers and floats are hashed directly;
+// other key types are hashed by hashValue, so that keys that are == always hash equally.
+func hashKey[K comparable](seed maphash.Seed, key K) uint64 {
+	var h maphash.Hash
+	h.SetSeed(seed)
+	var buf [8]byte
+	switch k := any(key).(type) {
+	case string:
+		h.WriteString(k)
+	case int:
+		h.Write(binary.LittleEndian.AppendUint64(buf[:0], uint64(k)))
+	case int8:
+		h.Write(binary.LittleEndian.AppendUint64(buf[:0], uint64(k)))
+	case int16:
+		h.Write(binary.LittleEndian.AppendUint64(buf[:0], uint64(k)))
+	case int32:
+		h.Write(binary.LittleEndian.AppendUint64(buf[:0], uint64(k)))
+	case int64:
+		h.Write(binary.LittleEndian.AppendUint64(buf[:0], uint64(k)))
+	case uint:
+		h.Write(binary.LittleEndian.AppendUint64(buf[:0], uint64(k)))
+	case uint8:
+		h.Write(binary.LittleEndian.AppendUint64(buf[:0], uint64(k)))
+	case uint16:
+		h.Write(binary.LittleEndian.AppendUint64(buf[:0], uint64(k)))
+	case uint32:
+		h.Write(binary.LittleEndian.AppendUint64(buf[:0], uint64(k)))
+	case uint64:
+		h.Write(binary.LittleEndian.AppendUint64(buf[:0], k))
+	case uintptr:
+		h.Write(binary.LittleEndian.AppendUint64(buf[:0], uint64(k)))
+	case float32:
+		// Adding zero maps -0 to +0, which compare equal.
+		h.Write(binary.LittleEndian.AppendUint64(buf[:0], math.Float64bits(float64(k)+0)))
+	case float64:
+		h.Write(binary.LittleEndian.AppendUint64(buf[:0], math.Float64bits(k+0)))
+	default:
+		hashValue(&h, reflect.ValueOf(&key).Elem())
+	}
+	return h.Sum64()
+}
+
+// hashValue writes the given value to h the way == compares it: pointers and channels by identity,
+// floats and complex numbers with -0 mapped to +0, and arrays, structs and interfaces element by element.
+// Like the built-in map, it panics for the dynamic types of interfaces that are not comparable.
+func hashValue(h *maphash.Hash, v reflect.Value) {
+	var buf [8]byte
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			h.WriteByte(1)
+		} else {
+			h.WriteByte(0)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		h.Write(binary.LittleEndian.AppendUint64(buf[:0], uint64(v.Int())))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		h.Write(binary.LittleEndian.AppendUint64(buf[:0], v.Uint()))
+	case reflect.Float32, reflect.Float64:
+		h.Write(binary.LittleEndian.AppendUint64(buf[:0], math.Float64bits(v.Float()+0)))
+	case reflect.Complex64, reflect.Complex128:
+		c := v.Complex()
+		h.Write(binary.LittleEndian.AppendUint64(buf[:0], math.Float64bits(real(c)+0)))
+		h.Write(binary.LittleEndian.AppendUint64(buf[:0], math.Float64bits(imag(c)+0)))
+	case reflect.String:
+		h.WriteString(v.String())
+	case reflect.Pointer, reflect.Chan, reflect.UnsafePointer:
+		h.Write(binary.LittleEndian.AppendUint64(buf[:0], uint64(v.Pointer())))
+	case reflect.Array:
+		for i := range v.Len() {
+			hashValue(h, v.Index(i))
+		}
+	case reflect.Struct:
+		for i := range v.NumField() {
+			hashValue(h, v.Field(i))
+		}
+	case reflect.Interface:
+		if v.IsNil() {
+			h.WriteByte(0)
+			return
+		}
+		h.WriteString(v.Elem().Type().String())
+		hashValue(h, v.Elem())
+	default:
+		panic(fmt.Sprintf("timedmap: hash of unhashable type %v", v.Type()))
+	}
+}
@@ -0,0 +1,124 @@
+package timedmap
+
+import (
+	"hash/maphash"
+	"math"
+	"math/rand/v2"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestShardedBasicCRUD(t *testing.T) {
+	sm := NewSharded[string, int](time.Minute, 8)
+	defer sm.Stop()
+	for i := range 100 {
+		sm.Put(strconv.Itoa(i), i, time.Second)
+	}
+	if sm.Size() != 100 {
+		t.Errorf("expected size 100, got %d", sm.Size())
+	}
+	if value, ok := sm.Get("42"); !ok || value != 42 {
+		t.Errorf("expected value 42, got %d", value)
+	}
+	sm.Delete("42")
+	if sm.Contains("42") {
+		t.Errorf("expected key to be deleted")
+	}
+	sm.Clear()
+	if sm.Size() != 0 {
+		t.Errorf("expected size 0, got %d", sm.Size())
+	}
+}
+
+func TestShardedCleanup(t *testing.T) {
+	sm := NewSharded[int, int](time.Millisecond, 4)
+	defer sm.Stop()
+	for i := range 10 {
+		sm.Put(i, i, time.Millisecond)
+	}
+	if !eventually(func() bool { return sm.Size() == 0 }) {
+		t.Errorf("expected all entries to be cleaned up, got size %d", sm.Size())
+	}
+}
+
+func TestShardedConcurrency(t *testing.T) {
+	sm := NewSharded[int, int](time.Minute, 16)
+	defer sm.Stop()
+	var wg sync.WaitGroup
+	for i := range 100 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sm.Put(i, i, time.Second)
+			sm.Get(i)
+		}()
+	}
+	wg.Wait()
+	if sm.Size() != 100 {
+		t.Errorf("expected size 100, got %d", sm.Size())
+	}
+}
+
+func TestHashKeyEqualValues(t *testing.T) {
+	type point struct{ x, y int }
+	sm := NewSharded[point, int](time.Minute, 16)
+	defer sm.Stop()
+	sm.Put(point{1, 2}, 19, time.Second)
+	if value, ok := sm.Get(point{1, 2}); !ok || value != 19 {
+		t.Errorf("expected value 19, got %d", value)
+	}
+	seed := maphash.MakeSeed()
+	if hashKey(seed, 0.0) != hashKey(seed, math.Copysign(0, -1)) {
+		t.Errorf("expected -0 and +0 to hash equally")
+	}
+}
+
+func TestHashKeyIdentity(t *testing.T) {
+	type conn struct{ n int }
+	sm := NewSharded[*conn, int](0, 64)
+	keys := make([]*conn, 100)
+	for i := range keys {
+		keys[i] = &conn{n: i}
+		sm.Put(keys[i], i, time.Minute)
+	}
+	for i, key := range keys {
+		key.n += 1000
+		if value, ok := sm.Get(key); !ok || value != i {
+			t.Errorf("expected pointer key %d to be found after its target changed, got %d", i, value)
+		}
+	}
+	type floatKey struct{ f float64 }
+	fm := NewSharded[floatKey, int](0, 64)
+	fm.Put(floatKey{0}, 19, time.Minute)
+	if value, ok := fm.Get(floatKey{math.Copysign(0, -1)}); !ok || value != 19 {
+		t.Errorf("expected a struct key holding -0 to find the one holding +0, got %d", value)
+	}
+	seed := maphash.MakeSeed()
+	if hashKey[any](seed, 1.5) != hashKey[any](seed, 1.5) || hashKey[any](seed, nil) != hashKey[any](seed, nil) {
+		t.Errorf("expected equal interface keys to hash equally")
+	}
+}
+
+func BenchmarkConcurrentPutSingleLock(b *testing.B) {
+	tm := New[int, int](time.Minute)
+	defer tm.Stop()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			i := rand.IntN(1 << 16)
+			tm.Put(i, i, time.Minute)
+		}
+	})
+}
+
+func BenchmarkConcurrentPutSharded(b *testing.B) {
+	sm := NewSharded[int, int](time.Minute, 32)
+	defer sm.Stop()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			i := rand.IntN(1 << 16)
+			sm.Put(i, i, time.Minute)
+		}
+	})
+}
@@ -1,103 +1,1892 @@
 package timedmap
 
 import (
+	"container/heap"
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"math/rand/v2"
+	"slices"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// [Clock] provides the current time to a [TimedMap].
+// Supplying a custom [Clock] via [NewWithClock] makes expiration deterministic in tests.
+// The [TimedMap] trusts the times it returns: times without a monotonic clock reading are compared
+// by their wall clock reading, so they are subject to adjustments of the system clock.
+type Clock interface {
+	Now() time.Time
+}
+
+// systemClock is the [Clock] backed by [time.Now].
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// [ExpirationPolicy] determines how the time-to-live of the entries of a [TimedMap] is interpreted.
+type ExpirationPolicy int
+
+const (
+	// Absolute expires entries a fixed time after they were added. It is the default policy.
+	Absolute ExpirationPolicy = iota
+	// Sliding expires entries once they have not been returned by [TimedMap.Get] for their time-to-live,
+	// resetting their expiration time on every successful Get.
+	Sliding
+)
+
+// State describes the state of a key as reported by [TimedMap.GetWithState].
+type State int
+
+const (
+	// Missing means the key does not exist or has expired.
+	Missing State = iota
+	// Present means the key holds a value that has not expired.
+	Present
+	// NegativeCached means the key is known to have no value, as recorded by [TimedMap.PutTombstone].
+	NegativeCached
+	// lapsed is returned by lookup instead of Missing if the key held an entry that has expired.
+	// GetWithState reports it as Missing.
+	lapsed State = -1
+)
+
+var (
+	// ErrNotFound is returned by [TimedMap.GetOrError] if the key does not exist.
+	ErrNotFound = errors.New("timedmap: key not found")
+	// ErrExpired is returned by [TimedMap.GetOrError] if the key exists but has expired.
+	ErrExpired = errors.New("timedmap: key expired")
+)
+
+// ExpirationChannelSize is the capacity of the channel returned by [TimedMap.ExpirationChannel].
+const ExpirationChannelSize = 128
+
+// StringLimit is the maximum number of entries described by [TimedMap.String].
+const StringLimit = 10
+
+// NoExpiration is the time-to-live reported for entries that never expire.
+const NoExpiration time.Duration = -1
+
 // [TimedMap] is a map that automatically removes entries that have expired.
 // It is useful for caching data that expires after a certain period of time.
 // This implementation uses a [sync.RWMutex] to synchronize access to the map and hence is thread-safe.
+//
+// With the default clock, expiration times are derived from [time.Now] and keep its monotonic clock reading,
+// so steps of the system clock, such as NTP corrections, make entries expire neither early nor late; see [time] for details.
+// Expiration times given as absolute times, to [TimedMap.SetExpiration] or by [TimedMap.UnmarshalJSON], have no
+// monotonic clock reading and are compared by their wall clock reading instead.
 type TimedMap[K comparable, V any] struct {
 	mu    sync.RWMutex
 	t     *time.Ticker
 	i     time.Duration
-	store map[K]*entry[V]
+	store map[K]*entry[K, V]
+	queue expirationHeap[K, V]
+	clock Clock
+	// ttl is the default time-to-live used by PutDefault.
+	ttl    time.Duration
+	policy ExpirationPolicy
+	done   chan struct{}
+	// exited is closed once the cleanup goroutine has returned.
+	exited chan struct{}
+	stop   sync.Once
+	hooks  hooks[K, V]
+	// expired and evicted collect the entries removed while the write lock is held,
+	// so that unlock can report them once the lock has been released.
+	expired, evicted []*entry[K, V]
+	// jitter is the fraction of the time-to-live by which expiresAt randomly shortens it, drawing from random if not nil.
+	jitter float64
+	random *rand.Rand
+	// capacity is the maximum number of entries and maxWeight the maximum total weight of the entries
+	// as measured by weigher, enforced only if lru is not nil. A capacity of zero or a nil weigher means no limit.
+	capacity  int
+	weigher   func(key K, value V) int64
+	maxWeight int64
+	// weight is the total weight of the entries, tracked only if weigher is not nil.
+	weight int64
+	// lru orders the entries from the most to the least recently used.
+	lru *list.List
+	// loader fetches the values of missing keys for Load, and calls holds the computations in progress, by key.
+	loader func(key K) (V, time.Duration, error)
+	calls  map[K]*call[V]
+	// waiters holds, for each key, the channels closed by wake once the entry has been removed.
+	waiters map[K][]chan struct{}
+	// paused makes the cleanup goroutine skip its sweeps while true.
+	paused atomic.Bool
+	// initial holds the entries given with WithInitialEntries until NewWithOptions restores them.
+	initial []Entry[K, V]
+	// Counters reported by Stats.
+	hits, misses, expirations, evictions atomic.Uint64
 }
 
 // New creates a new [TimedMap] with the given cleanup interval.
+// If the interval is zero or less, no background cleanup goroutine is started and expired entries
+// are only removed when they are accessed or by [TimedMap.CleanupNow].
 func New[K comparable, V any](interval time.Duration) *TimedMap[K, V] {
-	tm := &TimedMap[K, V]{
-		t:     time.NewTicker(interval),
-		i:     interval,
-		store: make(map[K]*entry[V]),
+	return NewWithOptions(WithCleanupInterval[K, V](interval))
+}
+
+// NewWithCleanupInterval creates a new [TimedMap] with the given cleanup interval, like [New].
+func NewWithCleanupInterval[K comparable, V any](interval time.Duration) *TimedMap[K, V] {
+	return NewWithOptions(WithCleanupInterval[K, V](interval))
+}
+
+// NewLazy creates a new [TimedMap] without a background cleanup goroutine.
+// Expired entries are only removed when they are accessed or by [TimedMap.CleanupNow].
+func NewLazy[K comparable, V any]() *TimedMap[K, V] {
+	return New[K, V](0)
+}
+
+// NewWithClock creates a new [TimedMap] with the given cleanup interval that reads the current time from clock.
+func NewWithClock[K comparable, V any](interval time.Duration, clock Clock) *TimedMap[K, V] {
+	return NewWithOptions(WithCleanupInterval[K, V](interval), WithClock[K, V](clock))
+}
+
+// NewWithCapacity creates a new [TimedMap] with the given cleanup interval that holds at most maxEntries entries.
+// When adding an entry would exceed the capacity, the least recently used entry is evicted first.
+// Evicted entries are passed to the callback registered with [TimedMap.OnEvict].
+// If maxEntries is zero or less, the [TimedMap] is unbounded.
+func NewWithCapacity[K comparable, V any](interval time.Duration, maxEntries int) *TimedMap[K, V] {
+	return NewWithOptions(WithCleanupInterval[K, V](interval), WithCapacity[K, V](maxEntries))
+}
+
+// NewWithWeigher creates a new [TimedMap] with the given cleanup interval whose entries weigh at most maxWeight in total,
+// the weight of each entry being measured by weigher when it is added or its value is changed.
+// When adding or changing an entry would exceed the maximum weight, the least recently used entries are evicted first,
+// so an entry heavier than maxWeight on its own is evicted right away. Evicted entries are passed to the callback
+// registered with [TimedMap.OnEvict]. If maxWeight is zero or less or weigher is nil, the [TimedMap] is unbounded.
+// weigher is called while the write lock is held, so it must not call any method of the [TimedMap].
+func NewWithWeigher[K comparable, V any](interval time.Duration, maxWeight int64, weigher func(key K, value V) int64) *TimedMap[K, V] {
+	return NewWithOptions(WithCleanupInterval[K, V](interval), WithWeigher(maxWeight, weigher))
+}
+
+// NewWithCapacityHint creates a new [TimedMap] with the given cleanup interval and room for about hint entries,
+// allocated up front to avoid growing the underlying map while it is populated. Unlike [NewWithCapacity],
+// it does not bound the number of entries.
+func NewWithCapacityHint[K comparable, V any](interval time.Duration, hint int) *TimedMap[K, V] {
+	return NewWithOptions(WithCleanupInterval[K, V](interval), WithCapacityHint[K, V](hint))
+}
+
+// NewWithDefaultTTL creates a new [TimedMap] with the given cleanup interval and the default time-to-live used by [TimedMap.PutDefault].
+func NewWithDefaultTTL[K comparable, V any](interval, defaultTTL time.Duration) *TimedMap[K, V] {
+	return NewWithOptions(WithCleanupInterval[K, V](interval), WithDefaultTTL[K, V](defaultTTL))
+}
+
+// NewWithExpirationPolicy creates a new [TimedMap] with the given cleanup interval and [ExpirationPolicy].
+func NewWithExpirationPolicy[K comparable, V any](interval time.Duration, policy ExpirationPolicy) *TimedMap[K, V] {
+	return NewWithOptions(WithCleanupInterval[K, V](interval), WithExpirationPolicy[K, V](policy))
+}
+
+// NewWithContext creates a new [TimedMap] with the given cleanup interval whose cleanup goroutine
+// is stopped automatically when ctx is done.
+func NewWithContext[K comparable, V any](ctx context.Context, interval time.Duration) *TimedMap[K, V] {
+	return NewWithOptions(WithCleanupInterval[K, V](interval), WithContext[K, V](ctx))
+}
+
+// newTimedMap creates a new [TimedMap] with the given cleanup interval without starting its cleanup goroutine.
+func newTimedMap[K comparable, V any](interval time.Duration) *TimedMap[K, V] {
+	return &TimedMap[K, V]{
+		i:      interval,
+		store:  make(map[K]*entry[K, V]),
+		clock:  systemClock{},
+		done:   make(chan struct{}),
+		exited: make(chan struct{}),
 	}
-	go tm.cleanup()
-	return tm
+}
+
+// initialize makes a zero [TimedMap], such as one allocated by a decoder, usable like one created by [NewLazy].
+// It does nothing for a [TimedMap] created by one of the constructors. It must be called with the write lock held.
+func (tm *TimedMap[K, V]) initialize() {
+	if tm.store != nil {
+		return
+	}
+	tm.store = make(map[K]*entry[K, V])
+	tm.clock = systemClock{}
+	tm.done = make(chan struct{})
+	tm.exited = make(chan struct{})
 }
 
 // Put adds a value and its time-to-live duration to the [TimedMap] for the given key.
+// A time-to-live of zero or less adds an entry that has already expired. Since [time.Time.Add] does not wrap around,
+// even the largest time-to-live, time.Duration(math.MaxInt64), yields an expiration time in the future.
 func (tm *TimedMap[K, V]) Put(key K, value V, ttl time.Duration) {
 	tm.mu.Lock()
-	defer tm.mu.Unlock()
-	tm.store[key] = &entry[V]{
-		value:      value,
-		expiration: time.Now().Add(ttl),
+	defer tm.unlock()
+	tm.set(key, value, tm.expiresAt(tm.clock.Now(), ttl))
+}
+
+// PutDefault adds a value to the [TimedMap] for the given key with the default time-to-live
+// configured by [NewWithDefaultTTL]. Without a configured default, that is with a default of zero,
+// the entry never expires, as if added by [TimedMap.PutPermanent].
+func (tm *TimedMap[K, V]) PutDefault(key K, value V) {
+	if tm.ttl == 0 {
+		tm.PutPermanent(key, value)
+		return
+	}
+	tm.Put(key, value, tm.ttl)
+}
+
+// PutAll adds all the given key-value pairs to the [TimedMap] with the same time-to-live duration.
+// The write lock is acquired only once for all entries.
+func (tm *TimedMap[K, V]) PutAll(entries map[K]V, ttl time.Duration) {
+	tm.mu.Lock()
+	defer tm.unlock()
+	now := tm.clock.Now()
+	for k, v := range entries {
+		tm.set(k, v, tm.expiresAt(now, ttl))
+	}
+}
+
+// Swap adds a value and its time-to-live duration to the [TimedMap] for the given key and returns the previous value, if any.
+// The existed result is true if the key existed and had not expired.
+func (tm *TimedMap[K, V]) Swap(key K, value V, ttl time.Duration) (old V, existed bool) {
+	tm.mu.Lock()
+	defer tm.unlock()
+	now := tm.clock.Now()
+	e, ok := tm.store[key]
+	if ok && e.expired(now) {
+		tm.expire(e)
+		ok = false
 	}
+	tm.set(key, value, tm.expiresAt(now, ttl))
+	if !ok || e.tombstone {
+		return old, false
+	}
+	return e.value, true
+}
+
+// PutPermanent adds a value that never expires to the [TimedMap] for the given key.
+// The entry is only removed when it is deleted or overwritten.
+func (tm *TimedMap[K, V]) PutPermanent(key K, value V) {
+	tm.mu.Lock()
+	defer tm.unlock()
+	tm.set(key, value, time.Time{})
+}
+
+// PutTombstone records that the given key has no value for the given time-to-live duration, replacing any existing value.
+// Until the tombstone expires, [TimedMap.GetWithState] reports the key as [NegativeCached],
+// while [TimedMap.Get] and the other read methods treat it like a missing key.
+// Tombstones are counted by [TimedMap.Size] but never passed to the expiration or eviction callbacks.
+func (tm *TimedMap[K, V]) PutTombstone(key K, ttl time.Duration) {
+	tm.mu.Lock()
+	defer tm.unlock()
+	now := tm.clock.Now()
+	// The tombstone flag must be set before insert evicts, which may remove the new entry itself.
+	tm.insert(&entry[K, V]{key: key, expiration: tm.expiresAt(now, ttl), ttl: ttl, insertedAt: now, tombstone: true})
+	tm.wake(key)
 }
 
 // Get returns the value associated with the given key and a boolean indicating if the key exists.
 // If the key does not exist, it returns a zero value and false.
 // If the key exists but has expired, it returns a zero value and false.
 // If the key exists and has not expired, it returns the value and true.
+// Under the [Sliding] policy, a successful Get also resets the expiration time of the entry.
 func (tm *TimedMap[K, V]) Get(key K) (V, bool) {
-	tm.mu.RLock()
-	defer tm.mu.RUnlock()
+	value, state := tm.GetWithState(key)
+	return value, state == Present
+}
+
+// GetWithState returns the value associated with the given key and the [State] of the key.
+// It behaves like [TimedMap.Get], but tells a key that is missing or has expired ([Missing])
+// apart from a key that is known to be absent because of [TimedMap.PutTombstone] ([NegativeCached]).
+func (tm *TimedMap[K, V]) GetWithState(key K) (V, State) {
+	value, _, state := tm.get(key)
+	if state == lapsed {
+		state = Missing
+	}
+	return value, state
+}
+
+// GetOrError returns the value associated with the given key like [TimedMap.Get], but reports why no value was found
+// as an error: [ErrExpired] if the key holds an entry that has expired, and [ErrNotFound] if it does not exist or is known
+// to be absent because of [TimedMap.PutTombstone]. Since expired entries are removed by the background cleanup
+// and by the first access that finds them, a key is only reported as expired until it has been removed.
+func (tm *TimedMap[K, V]) GetOrError(key K) (V, error) {
+	value, _, state := tm.get(key)
+	switch state {
+	case Present:
+		return value, nil
+	case lapsed:
+		return value, ErrExpired
+	default:
+		return value, ErrNotFound
+	}
+}
+
+// GetWithTTL returns the value associated with the given key, its remaining time-to-live and a boolean indicating
+// if the key exists. It behaves like [TimedMap.Get], but reads the value and the time-to-live at once, so that
+// they are consistent with each other. If the key never expires, its time-to-live is [NoExpiration].
+func (tm *TimedMap[K, V]) GetWithTTL(key K) (value V, remaining time.Duration, ok bool) {
+	value, remaining, state := tm.get(key)
+	return value, remaining, state == Present
+}
+
+// GetWithRefreshHint returns the value associated with the given key, a boolean indicating if the entry expires
+// within the given window and a boolean indicating if the key exists. It behaves like [TimedMap.Get], and needsRefresh
+// lets the caller reload a value ahead of its expiration. Entries that never expire never need a refresh.
+func (tm *TimedMap[K, V]) GetWithRefreshHint(key K, window time.Duration) (value V, needsRefresh bool, ok bool) {
+	value, remaining, state := tm.get(key)
+	return value, state == Present && remaining != NoExpiration && remaining < window, state == Present
+}
+
+// get implements [TimedMap.GetWithState], [TimedMap.GetWithTTL] and [TimedMap.GetWithRefreshHint].
+func (tm *TimedMap[K, V]) get(key K) (V, time.Duration, State) {
+	// Recording the access reorders the LRU list or moves the expiration, which requires the write lock.
+	return tm.lookup(key, tm.lru != nil || tm.policy == Sliding)
+}
+
+// lookup implements get, holding the write lock if write is true and the read lock otherwise.
+// Since removing an expired entry requires the write lock, a lookup under the read lock that finds one
+// releases it and starts over under the write lock, as the entry may have changed in between.
+func (tm *TimedMap[K, V]) lookup(key K, write bool) (V, time.Duration, State) {
+	lock, unlock := tm.mu.RLock, tm.mu.RUnlock
+	if write {
+		lock, unlock = tm.mu.Lock, tm.mu.Unlock
+	}
+	var zero V
+	lock()
+	e, ok := tm.store[key]
+	if !ok {
+		unlock()
+		tm.misses.Add(1)
+		return zero, 0, Missing
+	}
+	now := tm.clock.Now()
+	if e.expired(now) {
+		if !write {
+			unlock()
+			return tm.lookup(key, true)
+		}
+		tm.remove(e)
+		h := tm.hooks
+		unlock()
+		tm.misses.Add(1)
+		tm.report(h, []*entry[K, V]{e}, nil)
+		if e.tombstone {
+			// The key never held a value, so it is missing rather than expired.
+			return zero, 0, Missing
+		}
+		return zero, 0, lapsed
+	}
+	if e.tombstone {
+		unlock()
+		tm.misses.Add(1)
+		return zero, 0, NegativeCached
+	}
+	tm.hits.Add(1)
+	e.hits.Add(1)
+	tm.touch(e)
+	if tm.policy == Sliding && !e.expiration.IsZero() {
+		tm.extend(e, now, e.ttl)
+	}
+	value, remaining := e.value, NoExpiration
+	if !e.expiration.IsZero() {
+		remaining = e.expiration.Sub(now)
+	}
+	unlock()
+	return value, remaining, Present
+}
+
+// GetStale returns the value associated with the given key even if it has expired, a boolean indicating
+// if it has expired and a boolean indicating if the key exists. Like [TimedMap.Peek], it never removes the expired entry,
+// so that a stale value can be served while a fresh one is computed, but it records the access like [TimedMap.Get]:
+// a value that has not expired counts as a hit, while a stale value counts as a miss.
+func (tm *TimedMap[K, V]) GetStale(key K) (value V, expired bool, ok bool) {
+	lock, unlock := tm.mu.RLock, tm.mu.RUnlock
+	if tm.lru != nil || tm.policy == Sliding {
+		lock, unlock = tm.mu.Lock, tm.mu.Unlock
+	}
+	lock()
+	defer unlock()
+	e, ok := tm.store[key]
+	if !ok || e.tombstone {
+		tm.misses.Add(1)
+		return value, false, false
+	}
+	now := tm.clock.Now()
+	if e.expired(now) {
+		tm.misses.Add(1)
+		return e.value, true, true
+	}
+	tm.hits.Add(1)
+	e.hits.Add(1)
+	tm.touch(e)
+	if tm.policy == Sliding && !e.expiration.IsZero() {
+		tm.extend(e, now, e.ttl)
+	}
+	return e.value, false, true
+}
+
+// GetWithGrace returns the value associated with the given key, a boolean indicating if it has not expired
+// and a boolean indicating if the key exists. Within the grace period following its expiration time, an entry
+// is still returned with fresh set to false and is not removed, which lets a stale value be served while it is refreshed.
+// Beyond the grace period, the entry is removed like [TimedMap.Get] does. The background cleanup does not know about
+// the grace period and removes entries once they have expired, so it should run less often than the grace period lasts.
+// A stale value counts as a miss in [TimedMap.Stats].
+func (tm *TimedMap[K, V]) GetWithGrace(key K, grace time.Duration) (value V, fresh bool, ok bool) {
+	tm.mu.Lock()
+	defer tm.unlock()
+	e, ok := tm.store[key]
+	if !ok || e.tombstone {
+		tm.misses.Add(1)
+		return value, false, false
+	}
+	now := tm.clock.Now()
+	if !e.expired(now) {
+		tm.hits.Add(1)
+		e.hits.Add(1)
+		tm.touch(e)
+		if tm.policy == Sliding && !e.expiration.IsZero() {
+			tm.extend(e, now, e.ttl)
+		}
+		return e.value, true, true
+	}
+	tm.misses.Add(1)
+	if now.Before(e.expiration.Add(grace)) {
+		return e.value, false, true
+	}
+	tm.expire(e)
+	return value, false, false
+}
+
+// GetAndDelete removes the value associated with the given key and returns it with a boolean indicating if the key existed.
+// If the key does not exist or has expired, it returns a zero value and false.
+func (tm *TimedMap[K, V]) GetAndDelete(key K) (V, bool) {
+	var zero V
+	tm.mu.Lock()
+	defer tm.unlock()
 	e, ok := tm.store[key]
 	if !ok {
-		return *new(V), false
+		tm.misses.Add(1)
+		return zero, false
+	}
+	if e.expired(tm.clock.Now()) {
+		tm.expire(e)
+		tm.misses.Add(1)
+		return zero, false
+	}
+	if e.tombstone {
+		tm.misses.Add(1)
+		return zero, false
+	}
+	tm.hits.Add(1)
+	tm.remove(e)
+	return e.value, true
+}
+
+// GetAndReset returns the value associated with the given key and replaces it with zero and the given time-to-live,
+// in a single step, so that no concurrent change is lost between the two. It returns true if the key exists
+// and has not expired; otherwise it returns a zero value and false and leaves the [TimedMap] unchanged.
+func (tm *TimedMap[K, V]) GetAndReset(key K, zero V, ttl time.Duration) (V, bool) {
+	tm.mu.Lock()
+	defer tm.unlock()
+	var value V
+	now := tm.clock.Now()
+	e, ok := tm.store[key]
+	if !ok || !e.live(now) {
+		tm.misses.Add(1)
+		return value, false
 	}
-	if time.Now().After(e.expiration) {
-		delete(tm.store, key)
-		return *new(V), false
+	tm.hits.Add(1)
+	value = e.value
+	tm.set(key, zero, tm.expiresAt(now, ttl))
+	return value, true
+}
+
+// GetAndRefresh returns the value associated with the given key and resets its time-to-live in the same critical section.
+// If the key does not exist or has expired, it returns a zero value and false and nothing is refreshed.
+func (tm *TimedMap[K, V]) GetAndRefresh(key K, ttl time.Duration) (V, bool) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	e, ok := tm.store[key]
+	now := tm.clock.Now()
+	if !ok || !e.live(now) {
+		tm.misses.Add(1)
+		var zero V
+		return zero, false
 	}
+	tm.hits.Add(1)
+	tm.extend(e, now, ttl)
+	tm.touch(e)
 	return e.value, true
 }
 
-// Contains returns true if the [TimedMap] contains the given key, false otherwise.
+// GetAll returns the values associated with the given keys that exist and have not expired.
+// Missing or expired keys are absent from the result. The read lock is acquired only once for all keys,
+// and the current time is read only once, so that every key of the batch is checked against the same instant
+// however large it is.
+func (tm *TimedMap[K, V]) GetAll(keys []K) map[K]V {
+	tm.mu.RLock()
+	defer tm.mu.RUnlock()
+	now := tm.clock.Now()
+	values := make(map[K]V, len(keys))
+	for _, k := range keys {
+		if e, ok := tm.store[k]; ok && e.live(now) {
+			values[k] = e.value
+			tm.hits.Add(1)
+		} else {
+			tm.misses.Add(1)
+		}
+	}
+	return values
+}
+
+// Peek returns the value associated with the given key, a boolean indicating if it has expired
+// and a boolean indicating if the key exists. Unlike [TimedMap.Get], Peek never removes expired entries.
+func (tm *TimedMap[K, V]) Peek(key K) (value V, expired bool, ok bool) {
+	tm.mu.RLock()
+	defer tm.mu.RUnlock()
+	e, ok := tm.store[key]
+	if !ok || e.tombstone {
+		return value, false, false
+	}
+	return e.value, e.expired(tm.clock.Now()), true
+}
+
+// GetOrPut returns the existing value for the given key if it exists and has not expired.
+// Otherwise, it adds the given value and its time-to-live duration and returns it.
+// The loaded result is true if the value was loaded, false if it was added.
+func (tm *TimedMap[K, V]) GetOrPut(key K, value V, ttl time.Duration) (actual V, loaded bool) {
+	return tm.GetOrCompute(key, ttl, func() V {
+		return value
+	})
+}
+
+// PutIfAbsent adds a value and its time-to-live duration to the [TimedMap] for the given key
+// only if the key does not exist or has expired. It returns true if the value was added,
+// false if an entry that has not expired was already present, in which case it is left untouched.
+func (tm *TimedMap[K, V]) PutIfAbsent(key K, value V, ttl time.Duration) bool {
+	tm.mu.Lock()
+	defer tm.unlock()
+	now := tm.clock.Now()
+	e, ok := tm.store[key]
+	if ok && e.live(now) {
+		return false
+	}
+	if ok && e.expired(now) {
+		tm.expire(e)
+	}
+	tm.set(key, value, tm.expiresAt(now, ttl))
+	return true
+}
+
+// PutIfPresent replaces the value and the time-to-live duration of the given key only if the key exists
+// and has not expired, so that an expired or deleted entry is never brought back. It returns true if the value was replaced.
+func (tm *TimedMap[K, V]) PutIfPresent(key K, value V, ttl time.Duration) bool {
+	tm.mu.Lock()
+	defer tm.unlock()
+	now := tm.clock.Now()
+	e, ok := tm.store[key]
+	if !ok || !e.live(now) {
+		return false
+	}
+	tm.set(key, value, tm.expiresAt(now, ttl))
+	return true
+}
+
+// GetOrCompute returns the existing value for the given key if it exists and has not expired.
+// Otherwise, it calls f, adds its result with the given time-to-live duration and returns it.
+// The boolean result is true if the value was loaded, false if it was computed.
+// f is called while the write lock is held, so it must not call any method of the [TimedMap].
+func (tm *TimedMap[K, V]) GetOrCompute(key K, ttl time.Duration, f func() V) (V, bool) {
+	tm.mu.Lock()
+	defer tm.unlock()
+	now := tm.clock.Now()
+	e, ok := tm.store[key]
+	if ok && e.live(now) {
+		tm.touch(e)
+		tm.hits.Add(1)
+		return e.value, true
+	}
+	tm.misses.Add(1)
+	if ok && e.expired(now) {
+		tm.expire(e)
+	}
+	value := f()
+	tm.set(key, value, tm.expiresAt(now, ttl))
+	return value, false
+}
+
+// Contains returns true if the [TimedMap] contains the given key and it has not expired, false otherwise.
 func (tm *TimedMap[K, V]) Contains(key K) bool {
 	tm.mu.RLock()
 	defer tm.mu.RUnlock()
-	_, ok := tm.store[key]
-	return ok
+	e, ok := tm.store[key]
+	return ok && e.live(tm.clock.Now())
+}
+
+// Hits returns the number of times [TimedMap.Get] returned the value associated with the given key
+// and a boolean indicating if the key exists. If the key does not exist or has expired, it returns 0 and false.
+func (tm *TimedMap[K, V]) Hits(key K) (uint64, bool) {
+	tm.mu.RLock()
+	defer tm.mu.RUnlock()
+	e, ok := tm.store[key]
+	if !ok || !e.live(tm.clock.Now()) {
+		return 0, false
+	}
+	return e.hits.Load(), true
+}
+
+// TTL returns the remaining time-to-live of the given key and a boolean indicating if the key exists.
+// If the key does not exist or has expired, it returns 0 and false.
+// If the key never expires, it returns [NoExpiration] and true.
+func (tm *TimedMap[K, V]) TTL(key K) (time.Duration, bool) {
+	tm.mu.RLock()
+	defer tm.mu.RUnlock()
+	e, ok := tm.store[key]
+	if !ok || e.tombstone {
+		return 0, false
+	}
+	if e.expiration.IsZero() {
+		return NoExpiration, true
+	}
+	ttl := e.expiration.Sub(tm.clock.Now())
+	if ttl <= 0 {
+		return 0, false
+	}
+	return ttl, true
+}
+
+// Age returns the time elapsed since the given key was added, or last replaced, and a boolean indicating if the key exists.
+// Refreshing an entry or changing its value in place does not reset its age. If the key does not exist or has expired,
+// it returns 0 and false.
+func (tm *TimedMap[K, V]) Age(key K) (time.Duration, bool) {
+	tm.mu.RLock()
+	defer tm.mu.RUnlock()
+	now := tm.clock.Now()
+	e, ok := tm.store[key]
+	if !ok || !e.live(now) {
+		return 0, false
+	}
+	return now.Sub(e.insertedAt), true
+}
+
+// Inspect returns the value and all the metadata of the given key and a boolean indicating if the key exists.
+// If the key does not exist or has expired, it returns a zero [EntryInfo] and false.
+// Like [TimedMap.Peek], it does not count as an access.
+func (tm *TimedMap[K, V]) Inspect(key K) (EntryInfo[K, V], bool) {
+	tm.mu.RLock()
+	defer tm.mu.RUnlock()
+	now := tm.clock.Now()
+	e, ok := tm.store[key]
+	if !ok || !e.live(now) {
+		return EntryInfo[K, V]{}, false
+	}
+	item := e.export(now)
+	return EntryInfo[K, V]{
+		Key:        e.key,
+		Value:      e.value,
+		InsertedAt: e.insertedAt,
+		Expiration: e.expiration,
+		TTL:        item.TTL,
+		Hits:       e.hits.Load(),
+	}, true
+}
+
+// Refresh resets the time-to-live of the given key without changing its value.
+// It returns true if the key exists and has not expired, false otherwise.
+func (tm *TimedMap[K, V]) Refresh(key K, ttl time.Duration) bool {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	e, ok := tm.store[key]
+	if !ok {
+		return false
+	}
+	now := tm.clock.Now()
+	if !e.live(now) {
+		return false
+	}
+	tm.extend(e, now, ttl)
+	return true
+}
+
+// Touch resets the time-to-live of the given key like [TimedMap.Refresh] and returns the time-to-live it had left before,
+// or [NoExpiration] if it never expired. Both happen under a single lock, so the reported time is consistent with the reset.
+// If the key does not exist or has expired, it returns 0 and false.
+func (tm *TimedMap[K, V]) Touch(key K, ttl time.Duration) (previousRemaining time.Duration, ok bool) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	e, ok := tm.store[key]
+	if !ok {
+		return 0, false
+	}
+	now := tm.clock.Now()
+	if !e.live(now) {
+		return 0, false
+	}
+	previousRemaining = NoExpiration
+	if !e.expiration.IsZero() {
+		previousRemaining = e.expiration.Sub(now)
+	}
+	tm.extend(e, now, ttl)
+	return previousRemaining, true
+}
+
+// SetTTL sets the expiration time of the given key to the given time-to-live duration from now,
+// without changing its value. It returns true if the key exists and has not expired, false otherwise.
+func (tm *TimedMap[K, V]) SetTTL(key K, ttl time.Duration) bool {
+	return tm.Refresh(key, ttl)
+}
+
+// SetExpiration sets the expiration time of the given key to the given absolute time, without changing its value.
+// It returns true if the key exists and has not expired, false otherwise.
+func (tm *TimedMap[K, V]) SetExpiration(key K, at time.Time) bool {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	e, ok := tm.store[key]
+	now := tm.clock.Now()
+	if !ok || !e.live(now) {
+		return false
+	}
+	e.ttl = at.Sub(now)
+	tm.reschedule(e, at)
+	return true
+}
+
+// ExtendAll moves the expiration time of every entry in the [TimedMap] that has not expired by delta
+// and returns the number of entries adjusted. Entries that never expire are left untouched.
+// All entries are adjusted under a single write lock, so none of them can expire midway.
+func (tm *TimedMap[K, V]) ExtendAll(delta time.Duration) int {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	now := tm.clock.Now()
+	extended := 0
+	for _, e := range tm.queue {
+		if e.live(now) {
+			// The time-to-live moves too, so that the Sliding policy does not undo the extension.
+			e.expiration = e.expiration.Add(delta)
+			e.ttl += delta
+			extended++
+		}
+	}
+	// The entries are moved by the same delta, but the expired ones are not, so the order has to be restored.
+	heap.Init(&tm.queue)
+	return extended
+}
+
+// Update replaces the value of the given key with the result of applying f to its current value,
+// keeping its expiration time. It returns true if the key exists and has not expired, false otherwise.
+// f is called while the write lock is held, so it must not call any method of the [TimedMap].
+func (tm *TimedMap[K, V]) Update(key K, f func(old V) V) bool {
+	tm.mu.Lock()
+	defer tm.unlock()
+	e, ok := tm.store[key]
+	if !ok || !e.live(tm.clock.Now()) {
+		return false
+	}
+	e.value = f(e.value)
+	tm.touch(e)
+	tm.reweigh(e)
+	return true
+}
+
+// RenameKey moves the entry of oldKey to newKey, keeping its value, expiration time and other metadata.
+// If newKey already exists, its entry is replaced, as [TimedMap.Put] would. It returns true if the entry was moved,
+// false if oldKey does not exist or has expired, in which case the [TimedMap] is left unchanged.
+func (tm *TimedMap[K, V]) RenameKey(oldKey, newKey K) bool {
+	tm.mu.Lock()
+	defer tm.unlock()
+	e, ok := tm.store[oldKey]
+	if !ok || !e.live(tm.clock.Now()) {
+		return false
+	}
+	if oldKey == newKey {
+		return true
+	}
+	if existing, ok := tm.store[newKey]; ok {
+		tm.unlink(existing)
+	}
+	delete(tm.store, oldKey)
+	tm.wake(oldKey)
+	e.key = newKey
+	tm.store[newKey] = e
+	tm.reweigh(e)
+	return true
 }
 
 // Delete removes the value associated with the given key regardless of its expiration time.
 func (tm *TimedMap[K, V]) Delete(key K) {
 	tm.mu.Lock()
 	defer tm.mu.Unlock()
-	delete(tm.store, key)
+	if e, ok := tm.store[key]; ok {
+		tm.remove(e)
+	}
+}
+
+// DeleteAll removes the values associated with the given keys regardless of their expiration time
+// and returns the number of entries removed. Keys that do not exist are ignored.
+func (tm *TimedMap[K, V]) DeleteAll(keys ...K) int {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	removed := 0
+	for _, k := range keys {
+		if e, ok := tm.store[k]; ok {
+			tm.remove(e)
+			removed++
+		}
+	}
+	return removed
+}
+
+// CompareAndSwap replaces the value associated with the given key with new and resets its time-to-live
+// if it has not expired and is equal to old. It returns true if the value was swapped.
+// It panics if the value type is not comparable.
+func (tm *TimedMap[K, V]) CompareAndSwap(key K, old, new V, ttl time.Duration) bool {
+	tm.mu.Lock()
+	defer tm.unlock()
+	now := tm.clock.Now()
+	e, ok := tm.store[key]
+	if !ok || !e.live(now) || any(e.value) != any(old) {
+		return false
+	}
+	e.value = new
+	tm.extend(e, now, ttl)
+	tm.touch(e)
+	tm.reweigh(e)
+	return true
+}
+
+// CompareAndDelete removes the value associated with the given key if it has not expired and is equal to old.
+// It returns true if the value was removed. It panics if the value type is not comparable.
+func (tm *TimedMap[K, V]) CompareAndDelete(key K, old V) bool {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	e, ok := tm.store[key]
+	if !ok || !e.live(tm.clock.Now()) || any(e.value) != any(old) {
+		return false
+	}
+	tm.remove(e)
+	return true
+}
+
+// Filter removes the entries that have not expired for which keep returns false and returns the number of entries removed.
+// Expired entries are not passed to keep and are left to the cleanup.
+// keep is called while the write lock is held, so it must not call any method of the [TimedMap].
+func (tm *TimedMap[K, V]) Filter(keep func(key K, value V) bool) int {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	now := tm.clock.Now()
+	removed := 0
+	for _, e := range tm.store {
+		if e.live(now) && !keep(e.key, e.value) {
+			tm.remove(e)
+			removed++
+		}
+	}
+	return removed
 }
 
 // Clear removes all entries from the [TimedMap].
 func (tm *TimedMap[K, V]) Clear() {
 	tm.mu.Lock()
 	defer tm.mu.Unlock()
+	tm.clear()
+}
+
+// ClearWithCallback removes all entries from the [TimedMap] like [TimedMap.Clear], but passes the expired ones
+// to the callback registered with [TimedMap.OnExpire] and the others to the callback registered with [TimedMap.OnEvict],
+// so that the resources they hold are released. The entries that have not expired are not counted
+// as evictions by [TimedMap.Stats], since they are not evicted to respect the capacity or the maximum weight.
+func (tm *TimedMap[K, V]) ClearWithCallback() {
+	tm.mu.Lock()
+	now := tm.clock.Now()
+	var cleared []*entry[K, V]
+	for _, e := range tm.store {
+		if e.expired(now) {
+			tm.expired = append(tm.expired, e)
+		} else {
+			cleared = append(cleared, e)
+		}
+	}
+	tm.clear()
+	h := tm.hooks
+	tm.unlock()
+	h.notify(h.onEvict, cleared)
+}
+
+// Reset returns the [TimedMap] to the state it had when created: it removes all entries like [TimedMap.Clear]
+// and zeroes the counters reported by [TimedMap.Stats]. The callbacks, the configuration and the background cleanup
+// are left untouched, so the [TimedMap] can be reused without creating a new one.
+func (tm *TimedMap[K, V]) Reset() {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	tm.clear()
+	tm.hits.Store(0)
+	tm.misses.Store(0)
+	tm.expirations.Store(0)
+	tm.evictions.Store(0)
+}
+
+// clear removes all entries from the [TimedMap] without reporting them. It must be called with the write lock held.
+func (tm *TimedMap[K, V]) clear() {
 	clear(tm.store)
+	tm.queue = nil
+	tm.weight = 0
+	for key := range tm.waiters {
+		tm.wake(key)
+	}
+	if tm.lru != nil {
+		tm.lru.Init()
+	}
 }
 
-// Size returns the number of entries in the [TimedMap].
+// Size returns the number of entries in the [TimedMap] in constant time.
+// The count includes expired entries that have not been removed yet by the background cleanup
+// or by lazy expiration, so it may overcount between cleanups. Use [TimedMap.LiveSize] for an exact count.
 func (tm *TimedMap[K, V]) Size() int {
 	tm.mu.RLock()
 	defer tm.mu.RUnlock()
 	return len(tm.store)
 }
 
-type entry[V any] struct {
-	value      V
-	expiration time.Time
-}
+// LiveSize returns the number of entries in the [TimedMap] that have not expired.
+// Unlike [TimedMap.Size], it excludes expired entries that have not been removed yet, at the cost of a full scan.
+func (tm *TimedMap[K, V]) LiveSize() int {
+	tm.mu.RLock()
+	defer tm.mu.RUnlock()
+	now := tm.clock.Now()
+	size := 0
+	for _, e := range tm.store {
+		if e.live(now) {
+			size++
+		}
+	}
+	return size
+}
+
+// Count returns the number of entries that have not expired for which match returns true, without collecting them.
+// match is called while the read lock is held, so it must not call any method of the [TimedMap].
+func (tm *TimedMap[K, V]) Count(match func(key K, value V) bool) int {
+	tm.mu.RLock()
+	defer tm.mu.RUnlock()
+	now := tm.clock.Now()
+	count := 0
+	for _, e := range tm.store {
+		if e.live(now) && match(e.key, e.value) {
+			count++
+		}
+	}
+	return count
+}
+
+// WaitForExpiration blocks until the given key is gone and returns nil, or returns the error of ctx if it is done first.
+// A key is gone once its entry has been removed, whether by the background cleanup, a lazy removal on access,
+// a delete or an eviction; it returns immediately if the key does not exist or has already expired.
+// Without a background cleanup, an entry that nobody accesses after it expires may never be removed.
+func (tm *TimedMap[K, V]) WaitForExpiration(ctx context.Context, key K) error {
+	tm.mu.Lock()
+	if e, ok := tm.store[key]; !ok || !e.live(tm.clock.Now()) {
+		tm.mu.Unlock()
+		return nil
+	}
+	if tm.waiters == nil {
+		tm.waiters = make(map[K][]chan struct{})
+	}
+	ch := make(chan struct{})
+	tm.waiters[key] = append(tm.waiters[key], ch)
+	tm.mu.Unlock()
+	select {
+	case <-ch:
+		return nil
+	case <-ctx.Done():
+	}
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	i := slices.Index(tm.waiters[key], ch)
+	if i < 0 {
+		// The key was removed concurrently with the cancellation.
+		return nil
+	}
+	if tm.waiters[key] = slices.Delete(tm.waiters[key], i, i+1); len(tm.waiters[key]) == 0 {
+		delete(tm.waiters, key)
+	}
+	return ctx.Err()
+}
+
+// OnExpire registers a callback that is invoked for every entry removed because it has expired,
+// either by the background cleanup or lazily by [TimedMap.Get]. Passing nil removes the callback.
+// Panics raised by the callback are recovered and reported to the handler registered with [TimedMap.OnError].
+// The callback is invoked without holding the lock, so it may safely call back into the [TimedMap],
+// except for [TimedMap.StopAndDrain].
+func (tm *TimedMap[K, V]) OnExpire(f func(key K, value V)) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	tm.hooks.onExpire = f
+}
+
+// ExpirationChannel returns a channel that receives the key of every entry removed because it has expired,
+// either by the background cleanup or lazily on access. Every call returns the same channel, which is never closed.
+// The channel is buffered with room for [ExpirationChannelSize] keys; while the buffer is full, the keys of newly
+// expired entries are dropped, so a slow or absent reader never blocks the cleanup goroutine.
+func (tm *TimedMap[K, V]) ExpirationChannel() <-chan K {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	if tm.hooks.expiredKeys == nil {
+		tm.hooks.expiredKeys = make(chan K, ExpirationChannelSize)
+	}
+	return tm.hooks.expiredKeys
+}
 
-// cleanup removes expired entries from the [TimedMap]. It runs in a separate goroutine.
+// OnSweep registers a callback that is invoked once per cleanup pass, whether run in the background
+// or by [TimedMap.CleanupNow] or [TimedMap.DeleteExpired], with all the entries the pass removed because they had expired.
+// It is not invoked for passes that removed nothing, nor for entries removed lazily on access.
+// It complements the callback registered with [TimedMap.OnExpire], which is still invoked for each entry,
+// to process expired entries in batches. Passing nil removes the callback.
+// Panics raised by the callback are recovered and reported to the handler registered with [TimedMap.OnError].
+// The callback is invoked without holding the lock, so it may safely call back into the [TimedMap],
+// except for [TimedMap.StopAndDrain].
+func (tm *TimedMap[K, V]) OnSweep(f func(removed []Entry[K, V])) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	tm.hooks.onSweep = f
+}
+
+// OnEvict registers a callback that is invoked for every entry evicted to respect the capacity or the maximum weight
+// of a [TimedMap] created by [NewWithCapacity] or [NewWithWeigher]. Passing nil removes the callback.
+// Panics raised by the callback are recovered and reported to the handler registered with [TimedMap.OnError].
+// The callback is invoked without holding the lock, so it may safely call back into the [TimedMap],
+// except for [TimedMap.StopAndDrain].
+func (tm *TimedMap[K, V]) OnEvict(f func(key K, value V)) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	tm.hooks.onEvict = f
+}
+
+// String implements [fmt.Stringer]. It describes the number of entries in the [TimedMap] that have not expired
+// and, for debugging, up to [StringLimit] of them with their remaining time-to-live, in unspecified order.
+// Expired entries are excluded.
+func (tm *TimedMap[K, V]) String() string {
+	tm.mu.RLock()
+	defer tm.mu.RUnlock()
+	now := tm.clock.Now()
+	var b strings.Builder
+	size := 0
+	for _, e := range tm.store {
+		if !e.live(now) {
+			continue
+		}
+		size++
+		if size > StringLimit {
+			continue
+		}
+		b.WriteString(" ")
+		if e.expiration.IsZero() {
+			fmt.Fprintf(&b, "%v:%v", e.key, e.value)
+		} else {
+			fmt.Fprintf(&b, "%v:%v(%v)", e.key, e.value, e.expiration.Sub(now))
+		}
+	}
+	if size > StringLimit {
+		b.WriteString(" ...")
+	}
+	return fmt.Sprintf("TimedMap[%d]{%s}", size, strings.TrimPrefix(b.String(), " "))
+}
+
+// Stats returns a snapshot of the counters of the [TimedMap].
+func (tm *TimedMap[K, V]) Stats() Stats {
+	return Stats{
+		Hits:        tm.hits.Load(),
+		Misses:      tm.misses.Load(),
+		Expirations: tm.expirations.Load(),
+		Evictions:   tm.evictions.Load(),
+	}
+}
+
+// Keys returns a snapshot of the keys of all entries in the [TimedMap] that have not expired.
+// Expired entries are skipped but not removed. The order of the keys is unspecified.
+func (tm *TimedMap[K, V]) Keys() []K {
+	tm.mu.RLock()
+	defer tm.mu.RUnlock()
+	now := tm.clock.Now()
+	keys := make([]K, 0, len(tm.store))
+	for k, e := range tm.store {
+		if e.live(now) {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+// Values returns a snapshot of the values of all entries in the [TimedMap] that have not expired.
+// Expired entries are skipped but not removed. The order of the values is unspecified.
+func (tm *TimedMap[K, V]) Values() []V {
+	tm.mu.RLock()
+	defer tm.mu.RUnlock()
+	now := tm.clock.Now()
+	values := make([]V, 0, len(tm.store))
+	for _, e := range tm.store {
+		if e.live(now) {
+			values = append(values, e.value)
+		}
+	}
+	return values
+}
+
+// Items returns a snapshot of all entries in the [TimedMap] that have not expired, including their expiration time.
+// Expired entries are skipped but not removed. The order of the entries is unspecified.
+func (tm *TimedMap[K, V]) Items() []Entry[K, V] {
+	tm.mu.RLock()
+	defer tm.mu.RUnlock()
+	now := tm.clock.Now()
+	items := make([]Entry[K, V], 0, len(tm.store))
+	for _, e := range tm.store {
+		if e.live(now) {
+			items = append(items, e.export(now))
+		}
+	}
+	return items
+}
+
+// ExpiringSoon returns a snapshot of up to n entries in the [TimedMap] that have not expired,
+// in ascending order of expiration time. Entries that never expire are not included.
+// The entries are read from the expiration heap, so the cost grows with n rather than with the size of the [TimedMap].
+func (tm *TimedMap[K, V]) ExpiringSoon(n int) []Entry[K, V] {
+	tm.mu.RLock()
+	defer tm.mu.RUnlock()
+	now := tm.clock.Now()
+	entries := tm.queue.earliest(n, func(e *entry[K, V]) bool {
+		return e.live(now)
+	})
+	items := make([]Entry[K, V], len(entries))
+	for i, e := range entries {
+		items[i] = e.export(now)
+	}
+	return items
+}
+
+// ExpirationBounds returns the earliest and the latest expiration times of the entries in the [TimedMap]
+// that have not expired, and a boolean indicating if there is any such entry. Entries that never expire are ignored,
+// so ok is false for a [TimedMap] holding only such entries. The earliest time is when the next cleanup will have
+// work to do, and the latest when the [TimedMap] will only hold entries that never expire if nothing is added.
+func (tm *TimedMap[K, V]) ExpirationBounds() (earliest, latest time.Time, ok bool) {
+	tm.mu.RLock()
+	defer tm.mu.RUnlock()
+	now := tm.clock.Now()
+	for _, e := range tm.queue {
+		if !e.live(now) {
+			continue
+		}
+		if !ok || e.expiration.Before(earliest) {
+			earliest = e.expiration
+		}
+		if !ok || e.expiration.After(latest) {
+			latest = e.expiration
+		}
+		ok = true
+	}
+	return earliest, latest, ok
+}
+
+// Snapshot returns a snapshot of all entries in the [TimedMap] that have not expired,
+// including their remaining time-to-live. It is equivalent to [TimedMap.Items].
+func (tm *TimedMap[K, V]) Snapshot() []Entry[K, V] {
+	return tm.Items()
+}
+
+// Restore adds the given entries to the [TimedMap], recomputing their expiration time from their
+// remaining time-to-live relative to now. Entries marked as [Entry.Permanent] never expire, whatever their TTL,
+// and the other entries with a time-to-live of zero or less are dropped. Existing entries with the same keys are replaced.
+func (tm *TimedMap[K, V]) Restore(entries []Entry[K, V]) {
+	tm.mu.Lock()
+	defer tm.unlock()
+	tm.initialize()
+	now := tm.clock.Now()
+	for _, e := range entries {
+		var expiration time.Time
+		if !e.Permanent {
+			if e.TTL <= 0 {
+				continue
+			}
+			expiration = now.Add(e.TTL)
+		}
+		tm.set(e.Key, e.Value, expiration)
+	}
+}
+
+// Clone returns an independent copy of the [TimedMap] holding its entries that have not expired, with their expiration times.
+// The copy has the same cleanup interval, clock, default time-to-live, expiration policy, capacity and maximum weight, and starts its own cleanup goroutine,
+// but none of the registered callbacks or counters. Values are copied by assignment, so values holding pointers are shallow-copied.
+func (tm *TimedMap[K, V]) Clone() *TimedMap[K, V] {
+	tm.mu.RLock()
+	defer tm.mu.RUnlock()
+	clone := newTimedMap[K, V](tm.i)
+	clone.clock = tm.clock
+	clone.ttl = tm.ttl
+	clone.policy = tm.policy
+	if tm.lru != nil {
+		clone.capacity = tm.capacity
+		clone.weigher = tm.weigher
+		clone.maxWeight = tm.maxWeight
+		clone.lru = list.New()
+	}
+	now := tm.clock.Now()
+	if tm.lru != nil {
+		// Insert from the least to the most recently used entry to preserve the LRU order.
+		for el := tm.lru.Back(); el != nil; el = el.Prev() {
+			if e := el.Value.(*entry[K, V]); !e.expired(now) {
+				clone.copy(e)
+			}
+		}
+	} else {
+		for _, e := range tm.store {
+			if !e.expired(now) {
+				clone.copy(e)
+			}
+		}
+	}
+	clone.start()
+	return clone
+}
+
+// Merge copies the entries of other that have not expired into the [TimedMap], preserving their expiration times.
+// When both maps hold a live entry for the same key, the entry that expires later wins.
+// The entries of other are snapshotted before the [TimedMap] is locked, so the two locks are never held
+// at the same time and concurrent merges in opposite directions cannot deadlock.
+func (tm *TimedMap[K, V]) Merge(other *TimedMap[K, V]) {
+	if other == tm {
+		return
+	}
+	// The entries of other are copied under its lock, along with the time-to-live the Sliding policy extends them by.
+	other.mu.RLock()
+	otherNow := other.clock.Now()
+	var items []*entry[K, V]
+	for _, e := range other.store {
+		if e.live(otherNow) {
+			items = append(items, &entry[K, V]{key: e.key, value: e.value, expiration: e.expiration, ttl: e.ttl})
+		}
+	}
+	other.mu.RUnlock()
+	tm.mu.Lock()
+	defer tm.unlock()
+	now := tm.clock.Now()
+	for _, item := range items {
+		if e, ok := tm.store[item.key]; ok {
+			if e.expired(now) {
+				tm.expire(e)
+			} else if !expiresBefore(e.expiration, item.expiration) {
+				continue
+			}
+		}
+		if item.expiration.IsZero() || now.Before(item.expiration) {
+			item.insertedAt = now
+			tm.insert(item)
+		}
+	}
+}
+
+// Range calls f sequentially for each entry in the [TimedMap] that has not expired.
+// If f returns false, Range stops the iteration. Expired entries are skipped but not removed.
+// f is called while the read lock is held, so it must not call any method of the [TimedMap].
+func (tm *TimedMap[K, V]) Range(f func(key K, value V) bool) {
+	tm.mu.RLock()
+	defer tm.mu.RUnlock()
+	now := tm.clock.Now()
+	for k, e := range tm.store {
+		if !e.live(now) {
+			continue
+		}
+		if !f(k, e.value) {
+			return
+		}
+	}
+}
+
+// ForEach calls f for each entry in the [TimedMap] that has not expired and removes the expired entries it encounters,
+// passing them to the callback registered with [TimedMap.OnExpire] once the iteration is done.
+// f is called while the write lock is held, so it must not call any method of the [TimedMap].
+func (tm *TimedMap[K, V]) ForEach(f func(key K, value V)) {
+	tm.mu.Lock()
+	defer tm.unlock()
+	now := tm.clock.Now()
+	for _, e := range tm.store {
+		if e.expired(now) {
+			tm.expire(e)
+			continue
+		}
+		if !e.tombstone {
+			f(e.key, e.value)
+		}
+	}
+}
+
+// UpdateEach calls f for each entry in the [TimedMap] that has not expired, with its expiration time,
+// and applies the result: if keep is false the entry is removed, otherwise its expiration time is set
+// to newExpiration, a zero time meaning that it never expires. Expired entries are removed like [TimedMap.ForEach] does.
+// The whole pass happens under the write lock, so f must not call any method of the [TimedMap].
+func (tm *TimedMap[K, V]) UpdateEach(f func(key K, value V, expiration time.Time) (newExpiration time.Time, keep bool)) {
+	tm.mu.Lock()
+	defer tm.unlock()
+	now := tm.clock.Now()
+	for _, e := range tm.store {
+		if e.expired(now) {
+			tm.expire(e)
+			continue
+		}
+		if e.tombstone {
+			continue
+		}
+		expiration, keep := f(e.key, e.value, e.expiration)
+		if !keep {
+			tm.remove(e)
+			continue
+		}
+		if !expiration.IsZero() {
+			e.ttl = expiration.Sub(now)
+		}
+		tm.reschedule(e, expiration)
+	}
+}
+
+// All returns an iterator over the entries in the [TimedMap] that have not expired.
+// The iterator works on a snapshot taken under the read lock when the iteration starts,
+// so the loop body may safely call any method of the [TimedMap].
+func (tm *TimedMap[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		for _, item := range tm.Items() {
+			if !yield(item.Key, item.Value) {
+				return
+			}
+		}
+	}
+}
+
+// CleanupNow synchronously removes all expired entries from the [TimedMap], like the background cleanup does,
+// and returns the number of entries removed.
+func (tm *TimedMap[K, V]) CleanupNow() int {
+	removed, _ := tm.sweep()
+	return len(removed)
+}
+
+// DeleteExpired synchronously removes all expired entries from the [TimedMap] like [TimedMap.CleanupNow]
+// and returns them, in ascending order of expiration time, for batch processing.
+// The removed entries are also passed to the callback registered with [TimedMap.OnExpire].
+func (tm *TimedMap[K, V]) DeleteExpired() []Entry[K, V] {
+	removed, now := tm.sweep()
+	items := make([]Entry[K, V], 0, len(removed))
+	for _, e := range removed {
+		if !e.tombstone {
+			items = append(items, e.export(now))
+		}
+	}
+	return items
+}
+
+// Drain removes all expired entries from the [TimedMap] like [TimedMap.DeleteExpired] and returns a map
+// of the entries that remain, both in a single pass under the write lock, so that no entry can expire in between.
+// The removed entries are passed to the callback registered with [TimedMap.OnExpire]. Despite its name,
+// Drain leaves the entries it returns in the [TimedMap].
+func (tm *TimedMap[K, V]) Drain() map[K]V {
+	tm.mu.Lock()
+	defer tm.unlock()
+	now := tm.clock.Now()
+	for len(tm.queue) > 0 && tm.queue[0].expired(now) {
+		tm.expire(tm.queue[0])
+	}
+	values := make(map[K]V, len(tm.store))
+	for k, e := range tm.store {
+		if !e.tombstone {
+			values[k] = e.value
+		}
+	}
+	return values
+}
+
+// SetExpirationJitter makes the [TimedMap] shorten the time-to-live of every entry it adds by a random amount
+// of up to the given fraction of it, so that entries added together with the same time-to-live do not all expire
+// at once. Entries never outlive the time-to-live they were given. The jitter is drawn from src, which can be seeded
+// for deterministic tests, or from the global random source if src is nil. A fraction of zero disables the jitter,
+// and fractions greater than one are treated as one. Refreshing an entry does not apply the jitter.
+func (tm *TimedMap[K, V]) SetExpirationJitter(fraction float64, src rand.Source) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	tm.jitter = min(max(fraction, 0), 1)
+	tm.random = nil
+	if src != nil {
+		tm.random = rand.New(src)
+	}
+}
+
+// CleanupInterval returns the interval of the background cleanup of the [TimedMap], as given to its constructor
+// or to the last call to [TimedMap.SetCleanupInterval]. An interval of zero or less means the background cleanup is paused.
+func (tm *TimedMap[K, V]) CleanupInterval() time.Duration {
+	tm.mu.RLock()
+	defer tm.mu.RUnlock()
+	return tm.i
+}
+
+// SetCleanupInterval changes the interval of the background cleanup of the [TimedMap].
+// An interval of zero or less pauses the background cleanup until a positive interval is set.
+// Setting a positive interval on a [TimedMap] without a cleanup goroutine starts one, unless it has been stopped.
+func (tm *TimedMap[K, V]) SetCleanupInterval(interval time.Duration) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	tm.i = interval
+	if tm.t == nil {
+		select {
+		case <-tm.done:
+		default:
+			tm.start()
+		}
+		return
+	}
+	if interval <= 0 {
+		tm.t.Stop()
+		return
+	}
+	tm.t.Reset(interval)
+}
+
+// PauseCleanup makes the background cleanup of the [TimedMap] skip its sweeps until [TimedMap.ResumeCleanup] is called,
+// so that it does not contend for the lock during a bulk load. The cleanup goroutine keeps running and ticking
+// at its interval, and expired entries are still removed lazily when accessed or by [TimedMap.CleanupNow].
+func (tm *TimedMap[K, V]) PauseCleanup() {
+	tm.paused.Store(true)
+}
+
+// ResumeCleanup resumes the background cleanup paused by [TimedMap.PauseCleanup], starting with its next tick.
+func (tm *TimedMap[K, V]) ResumeCleanup() {
+	tm.paused.Store(false)
+}
+
+// OnError registers a handler that is invoked with a [*CallbackError] whenever a callback registered
+// with [TimedMap.OnExpire], [TimedMap.OnEvict] or [TimedMap.OnSweep] panics. Such panics are always recovered, so a faulty
+// callback never terminates the background cleanup. Passing nil removes the handler.
+func (tm *TimedMap[K, V]) OnError(f func(err error)) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	tm.hooks.onError = f
+}
+
+// Stop terminates the background cleanup goroutine of the [TimedMap].
+// It is safe to call Stop more than once. After Stop the [TimedMap] remains usable,
+// but expired entries are only removed lazily when they are accessed.
+func (tm *TimedMap[K, V]) Stop() {
+	tm.stop.Do(func() {
+		close(tm.done)
+	})
+}
+
+// StopAndDrain stops the background cleanup like [TimedMap.Stop] and then removes all entries, passing the expired ones
+// to the callback registered with [TimedMap.OnExpire] and the others to the callback registered with [TimedMap.OnEvict],
+// so that the resources they hold can be released on shutdown. StopAndDrain first waits for a cleanup in progress
+// to complete, so that all the callbacks have returned by the time StopAndDrain does.
+// It must therefore not be called from a callback, which the background cleanup may be running and would wait for
+// forever; call [TimedMap.Stop] and [TimedMap.ClearWithCallback] there instead.
+func (tm *TimedMap[K, V]) StopAndDrain() {
+	tm.Stop()
+	tm.mu.RLock()
+	started := tm.t != nil
+	tm.mu.RUnlock()
+	if started {
+		<-tm.exited
+	}
+	tm.ClearWithCallback()
+}
+
+// [Entry] is a snapshot of a single entry of a [TimedMap].
+type Entry[K comparable, V any] struct {
+	Key   K
+	Value V
+	// Expiration is the zero [time.Time] for entries that never expire.
+	Expiration time.Time
+	// TTL is the remaining time-to-live when the snapshot was taken, or [NoExpiration] for entries that never expire.
+	TTL time.Duration
+	// Permanent reports whether the entry never expires. [TimedMap.Restore] relies on it rather than on TTL,
+	// since [NoExpiration] is also the remaining time-to-live of an entry that expired a nanosecond ago.
+	Permanent bool
+}
+
+// [EntryInfo] holds the value and the metadata of a single entry of a [TimedMap], as returned by [TimedMap.Inspect].
+type EntryInfo[K comparable, V any] struct {
+	Key   K
+	Value V
+	// InsertedAt is the time the entry was added, as reported by [TimedMap.Age].
+	InsertedAt time.Time
+	// Expiration is the zero [time.Time] for entries that never expire.
+	Expiration time.Time
+	// TTL is the remaining time-to-live when the entry was inspected, or [NoExpiration] for entries that never expire.
+	TTL time.Duration
+	// Hits is the number of calls to [TimedMap.Get] that returned the entry, as reported by [TimedMap.Hits].
+	Hits uint64
+}
+
+// [Stats] holds the counters of a [TimedMap].
+type Stats struct {
+	// Hits is the number of lookups that found a value, and Misses the number of lookups that found none.
+	// Every method that reads the value of a key counts as a lookup: [TimedMap.Get] and its variants,
+	// [TimedMap.GetAll] once per key, GetAndDelete, GetAndRefresh, GetAndReset, GetOrPut, GetOrCompute,
+	// GetOrComputeOnce, Load, WarmUp and [Txn.Get]. Peek, Contains, Inspect and the iterations are not counted.
+	Hits   uint64
+	Misses uint64
+	// Expirations is the number of entries removed because they have expired.
+	Expirations uint64
+	// Evictions is the number of entries evicted to respect the capacity or the maximum weight.
+	Evictions uint64
+}
+
+type entry[K comparable, V any] struct {
+	key   K
+	value V
+	// expiration is the zero [time.Time] for entries that never expire.
+	expiration time.Time
+	// element is the position of the entry in the LRU list, if any.
+	element *list.Element
+	// index is the position of the entry in the expiration heap, or -1 if it never expires.
+	index int
+	// hits is the number of successful calls to Get that returned the entry.
+	hits atomic.Uint64
+	// ttl is the time-to-live the entry was last given, used to extend it under the [Sliding] policy.
+	ttl time.Duration
+	// insertedAt is the time the entry was added, which replacing its value resets.
+	insertedAt time.Time
+	// weight is the weight of the entry as measured by the weigher of the [TimedMap], if any.
+	weight int64
+	// tombstone marks an entry added by PutTombstone, which records that the key has no value.
+	tombstone bool
+}
+
+// expired reports whether the entry has expired at the given time.
+// An entry expires at its expiration time, so a time-to-live of zero or less yields an already expired entry.
+func (e *entry[K, V]) expired(now time.Time) bool {
+	return !e.expiration.IsZero() && !now.Before(e.expiration)
+}
+
+// expiresBefore reports whether an entry expiring at a expires before one expiring at b,
+// where the zero [time.Time] means never.
+func expiresBefore(a, b time.Time) bool {
+	switch {
+	case a.IsZero():
+		return false
+	case b.IsZero():
+		return true
+	default:
+		return a.Before(b)
+	}
+}
+
+// live reports whether the entry holds a value that has not expired at the given time.
+func (e *entry[K, V]) live(now time.Time) bool {
+	return !e.tombstone && !e.expired(now)
+}
+
+// export returns a snapshot of the entry taken at the given time.
+func (e *entry[K, V]) export(now time.Time) Entry[K, V] {
+	ttl := NoExpiration
+	if !e.expiration.IsZero() {
+		ttl = e.expiration.Sub(now)
+	}
+	return Entry[K, V]{
+		Key:        e.key,
+		Value:      e.value,
+		Expiration: e.expiration,
+		TTL:        ttl,
+		Permanent:  e.expiration.IsZero(),
+	}
+}
+
+// start starts the cleanup goroutine of the [TimedMap] if its cleanup interval is positive.
+func (tm *TimedMap[K, V]) start() {
+	if tm.i <= 0 {
+		return
+	}
+	tm.t = time.NewTicker(tm.i)
+	go tm.cleanup()
+}
+
+// cleanup periodically removes expired entries from the [TimedMap]. It runs in a separate goroutine until [TimedMap.Stop] is called.
+// Sweeps are driven by a [time.Ticker], so their cadence does not drift by the time each sweep takes.
 func (tm *TimedMap[K, V]) cleanup() {
-	for range tm.t.C {
-		tm.mu.Lock()
-		now := time.Now()
-		for k, e := range tm.store {
-			if now.After(e.expiration) {
-				delete(tm.store, k)
+	defer close(tm.exited)
+	defer tm.t.Stop()
+	for {
+		select {
+		case <-tm.t.C:
+			if !tm.paused.Load() {
+				tm.sweep()
 			}
+		case <-tm.done:
+			return
 		}
-		tm.mu.Unlock()
 	}
 }
+
+// sweep removes all expired entries from the [TimedMap] and returns them in ascending order of expiration time,
+// along with the time they were found expired at. Once the lock has been released, the entries are reported
+// like unlock does and passed to the onSweep callback.
+func (tm *TimedMap[K, V]) sweep() ([]*entry[K, V], time.Time) {
+	tm.mu.Lock()
+	now := tm.clock.Now()
+	var removed []*entry[K, V]
+	for len(tm.queue) > 0 && tm.queue[0].expired(now) {
+		removed = append(removed, tm.queue[0])
+		tm.expire(tm.queue[0])
+	}
+	h := tm.hooks
+	tm.unlock()
+	if h.onSweep == nil {
+		return removed, now
+	}
+	items := make([]Entry[K, V], 0, len(removed))
+	for _, e := range removed {
+		if !e.tombstone {
+			items = append(items, e.export(now))
+		}
+	}
+	if len(items) > 0 {
+		h.call(func() {
+			h.onSweep(items)
+		})
+	}
+	return removed, now
+}
+
+// set stores the given value and expiration for the given key, replacing any existing entry,
+// and evicts the least recently used entries to respect the capacity and the maximum weight. It must be called with the write lock held.
+func (tm *TimedMap[K, V]) set(key K, value V, expiration time.Time) {
+	now := tm.clock.Now()
+	e := &entry[K, V]{key: key, value: value, expiration: expiration, insertedAt: now}
+	if !expiration.IsZero() {
+		e.ttl = expiration.Sub(now)
+	}
+	tm.insert(e)
+}
+
+// insert stores the given new entry like set does, once its key, value, expiration, time-to-live,
+// insertion time and tombstone flag have been set. It must be called with the write lock held.
+func (tm *TimedMap[K, V]) insert(e *entry[K, V]) {
+	if old, ok := tm.store[e.key]; ok {
+		tm.unlink(old)
+	}
+	e.index = -1
+	tm.store[e.key] = e
+	if !e.expiration.IsZero() {
+		heap.Push(&tm.queue, e)
+	}
+	if tm.lru == nil {
+		return
+	}
+	if tm.weigher != nil {
+		e.weight = tm.weigher(e.key, e.value)
+		tm.weight += e.weight
+	}
+	e.element = tm.lru.PushFront(e)
+	tm.evict()
+}
+
+// evict evicts the least recently used entries until the [TimedMap] respects its capacity and maximum weight,
+// recording them to be reported by unlock. It must be called with the write lock held.
+func (tm *TimedMap[K, V]) evict() {
+	for tm.lru.Len() > 0 && (tm.capacity > 0 && tm.lru.Len() > tm.capacity || tm.weigher != nil && tm.weight > tm.maxWeight) {
+		oldest := tm.lru.Back().Value.(*entry[K, V])
+		tm.remove(oldest)
+		tm.evicted = append(tm.evicted, oldest)
+	}
+}
+
+// reweigh updates the weight of the given entry after its value has changed in place,
+// evicting entries if the maximum weight is exceeded. It must be called with the write lock held.
+func (tm *TimedMap[K, V]) reweigh(e *entry[K, V]) {
+	if tm.weigher == nil {
+		return
+	}
+	tm.weight -= e.weight
+	e.weight = tm.weigher(e.key, e.value)
+	tm.weight += e.weight
+	tm.evict()
+}
+
+// copy stores a copy of the given entry of another [TimedMap]. It must be called with the write lock held.
+func (tm *TimedMap[K, V]) copy(e *entry[K, V]) {
+	tm.insert(&entry[K, V]{
+		key:        e.key,
+		value:      e.value,
+		expiration: e.expiration,
+		ttl:        e.ttl,
+		insertedAt: e.insertedAt,
+		tombstone:  e.tombstone,
+	})
+}
+
+// expire removes the given expired entry from the [TimedMap] and records it to be reported by unlock.
+// It must be called with the write lock held.
+func (tm *TimedMap[K, V]) expire(e *entry[K, V]) {
+	tm.remove(e)
+	tm.expired = append(tm.expired, e)
+}
+
+// remove deletes the given entry from the [TimedMap]. It must be called with the write lock held.
+func (tm *TimedMap[K, V]) remove(e *entry[K, V]) {
+	tm.unlink(e)
+	tm.wake(e.key)
+}
+
+// unlink removes the given entry from the map, the expiration heap and the LRU list without waking the waiters
+// of its key, so that set can replace it. It must be called with the write lock held.
+func (tm *TimedMap[K, V]) unlink(e *entry[K, V]) {
+	delete(tm.store, e.key)
+	tm.weight -= e.weight
+	if e.index >= 0 {
+		heap.Remove(&tm.queue, e.index)
+	}
+	if e.element != nil {
+		tm.lru.Remove(e.element)
+		e.element = nil
+	}
+}
+
+// reschedule changes the expiration time of the given entry. It must be called with the write lock held.
+func (tm *TimedMap[K, V]) reschedule(e *entry[K, V], expiration time.Time) {
+	e.expiration = expiration
+	switch {
+	case e.index >= 0 && expiration.IsZero():
+		heap.Remove(&tm.queue, e.index)
+	case e.index >= 0:
+		heap.Fix(&tm.queue, e.index)
+	case !expiration.IsZero():
+		heap.Push(&tm.queue, e)
+	}
+}
+
+// expiresAt returns the expiration time of an entry added at now with the given time-to-live,
+// shortened by a random jitter if one has been set with SetExpirationJitter. It must be called with the write lock held.
+func (tm *TimedMap[K, V]) expiresAt(now time.Time, ttl time.Duration) time.Time {
+	if tm.jitter > 0 && ttl > 0 {
+		random := rand.Float64
+		if tm.random != nil {
+			random = tm.random.Float64
+		}
+		ttl -= time.Duration(random() * tm.jitter * float64(ttl))
+	}
+	return now.Add(ttl)
+}
+
+// extend sets the time-to-live of the given entry to ttl from now. It must be called with the write lock held.
+func (tm *TimedMap[K, V]) extend(e *entry[K, V], now time.Time, ttl time.Duration) {
+	e.ttl = ttl
+	tm.reschedule(e, now.Add(ttl))
+}
+
+// touch marks the given entry as the most recently used. It must be called with the write lock held.
+func (tm *TimedMap[K, V]) touch(e *entry[K, V]) {
+	if e.element != nil {
+		tm.lru.MoveToFront(e.element)
+	}
+}
+
+// wake closes the channels of the callers of WaitForExpiration waiting for the given key.
+// It must be called with the write lock held.
+func (tm *TimedMap[K, V]) wake(key K) {
+	for _, ch := range tm.waiters[key] {
+		close(ch)
+	}
+	delete(tm.waiters, key)
+}
+
+// unlock releases the write lock and then reports the entries that expired or were evicted while it was held.
+func (tm *TimedMap[K, V]) unlock() {
+	expired, evicted, h := tm.expired, tm.evicted, tm.hooks
+	tm.expired, tm.evicted = nil, nil
+	tm.mu.Unlock()
+	tm.report(h, expired, evicted)
+}
+
+// report updates the counters and invokes the given hooks for the given expired and evicted entries.
+// It must be called without holding the lock.
+func (tm *TimedMap[K, V]) report(h hooks[K, V], expired, evicted []*entry[K, V]) {
+	tm.expirations.Add(countValues(expired))
+	tm.evictions.Add(countValues(evicted))
+	h.notify(h.onExpire, expired)
+	h.notify(h.onEvict, evicted)
+	h.send(expired)
+}
+
+// countValues returns the number of the given entries that are not tombstones, which are not counted by [TimedMap.Stats].
+func countValues[K comparable, V any](entries []*entry[K, V]) uint64 {
+	n := uint64(0)
+	for _, e := range entries {
+		if !e.tombstone {
+			n++
+		}
+	}
+	return n
+}
+
+// hooks holds the callbacks registered on a [TimedMap].
+type hooks[K comparable, V any] struct {
+	// onExpire is invoked for every entry removed because it has expired.
+	onExpire func(key K, value V)
+	// onEvict is invoked for every entry evicted to respect the capacity or the maximum weight.
+	onEvict func(key K, value V)
+	// onSweep is invoked once per cleanup pass that removed at least one entry.
+	onSweep func(removed []Entry[K, V])
+	// onError is invoked with a [*CallbackError] for every panic recovered from the other callbacks.
+	onError func(err error)
+	// expiredKeys receives the key of every entry removed because it has expired, if not nil.
+	expiredKeys chan K
+}
+
+// notify invokes f for each of the given entries, recovering from panics so that a faulty callback
+// cannot terminate the cleanup goroutine. It must be called without holding the lock.
+func (h hooks[K, V]) notify(f func(key K, value V), entries []*entry[K, V]) {
+	if f == nil {
+		return
+	}
+	for _, e := range entries {
+		if e.tombstone {
+			continue
+		}
+		h.call(func() {
+			f(e.key, e.value)
+		})
+	}
+}
+
+// send delivers the keys of the given expired entries to expiredKeys, dropping the keys that do not fit in its buffer.
+func (h hooks[K, V]) send(entries []*entry[K, V]) {
+	if h.expiredKeys == nil {
+		return
+	}
+	for _, e := range entries {
+		if e.tombstone {
+			continue
+		}
+		select {
+		case h.expiredKeys <- e.key:
+		default:
+		}
+	}
+}
+
+// call invokes f and reports a panic raised by it to onError, if any.
+func (h hooks[K, V]) call(f func()) {
+	defer func() {
+		if r := recover(); r != nil && h.onError != nil {
+			defer func() {
+				// A panicking error handler is ignored rather than allowed to crash the caller.
+				_ = recover()
+			}()
+			h.onError(&CallbackError{Value: r})
+		}
+	}()
+	f()
+}
+
+// [CallbackError] reports a panic recovered from a callback registered on a [TimedMap].
+type CallbackError struct {
+	// Value is the value passed to panic.
+	Value any
+}
+
+func (e *CallbackError) Error() string {
+	return fmt.Sprintf("timedmap: callback panicked: %v", e.Value)
+}
+
+// Unwrap returns the value passed to panic if it is an error, nil otherwise.
+func (e *CallbackError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
@@ -1,7 +1,16 @@
 package timedmap
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -29,9 +38,10 @@ func TestTimedMapGetNonExistentKey(t *testing.T) {
 }
 
 func TestTimedMapGetExpiredKey(t *testing.T) {
-	tm := New[string, int](time.Minute)
+	clock := newFakeClock()
+	tm := NewWithClock[string, int](time.Minute, clock)
 	tm.Put("key", 19, 100*time.Millisecond)
-	time.Sleep(200 * time.Millisecond)
+	clock.Advance(200 * time.Millisecond)
 	_, ok := tm.Get("key")
 	if ok {
 		t.Errorf("expected ok to be false")
@@ -81,6 +91,10 @@ func TestTimedMapContains(t *testing.T) {
 	if tm.Contains("key") {
 		t.Errorf("expected key to be removed")
 	}
+	tm.Put("expired", 23, -time.Second)
+	if tm.Contains("expired") {
+		t.Errorf("expected expired key not to be present")
+	}
 }
 
 func TestTimedMapClear(t *testing.T) {
@@ -94,10 +108,11 @@ func TestTimedMapClear(t *testing.T) {
 }
 
 func TestTimedMapExpiration(t *testing.T) {
-	tm := New[string, int](time.Minute)
+	clock := newFakeClock()
+	tm := NewWithClock[string, int](time.Minute, clock)
 	tm.Put("key1", 19, 300*time.Millisecond)
 	tm.Put("key2", 23, 100*time.Millisecond)
-	time.Sleep(200 * time.Millisecond)
+	clock.Advance(200 * time.Millisecond)
 	_, ok := tm.Get("key1")
 	if !ok {
 		t.Errorf("expected key1 to still be present")
@@ -109,11 +124,12 @@ func TestTimedMapExpiration(t *testing.T) {
 }
 
 func TestTimedMapCleanup(t *testing.T) {
-	tm := New[string, int](200 * time.Millisecond)
+	clock := newFakeClock()
+	tm := NewWithClock[string, int](time.Millisecond, clock)
+	defer tm.Stop()
 	tm.Put("key", 19, 100*time.Millisecond)
-	time.Sleep(300 * time.Millisecond)
-	_, ok := tm.Get("key")
-	if ok {
+	clock.Advance(100 * time.Millisecond)
+	if !eventually(func() bool { return tm.Size() == 0 }) {
 		t.Errorf("expected key to be cleaned up and removed")
 	}
 }
@@ -139,3 +155,1830 @@ func TestTimedMapConcurrency(t *testing.T) {
 		t.Errorf("expected value to exist for key, but it was missing")
 	}
 }
+
+func TestTimedMapStop(t *testing.T) {
+	clock := newFakeClock()
+	tm := NewWithClock[string, int](time.Millisecond, clock)
+	tm.Stop()
+	tm.Stop()
+	<-tm.exited
+	tm.Put("key", 19, 50*time.Millisecond)
+	clock.Advance(50 * time.Millisecond)
+	if tm.Size() != 1 {
+		t.Errorf("expected size 1 after Stop, got %d", tm.Size())
+	}
+	if _, ok := tm.Get("key"); ok {
+		t.Errorf("expected key to be expired")
+	}
+}
+
+func TestTimedMapNewWithContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	tm := NewWithContext[string, int](ctx, time.Minute)
+	cancel()
+	select {
+	case <-tm.done:
+	case <-time.After(time.Second):
+		t.Fatalf("expected cleanup to stop after context cancellation")
+	}
+	tm.Put("key", 19, time.Second)
+	if value, ok := tm.Get("key"); !ok || value != 19 {
+		t.Errorf("expected value 19, got %d", value)
+	}
+}
+
+func TestTimedMapOnExpire(t *testing.T) {
+	clock := newFakeClock()
+	tm := NewWithClock[string, int](time.Millisecond, clock)
+	defer tm.Stop()
+	var mu sync.Mutex
+	expired := make(map[string]int)
+	tm.OnExpire(func(key string, value int) {
+		mu.Lock()
+		expired[key] = value
+		mu.Unlock()
+		// The callback runs without the lock held, so re-entering the map must not deadlock.
+		tm.Put("reentrant", value, time.Minute)
+	})
+	tm.Put("key1", 19, 50*time.Millisecond)
+	tm.Put("key2", 23, 50*time.Millisecond)
+	clock.Advance(50 * time.Millisecond)
+	if _, ok := tm.Get("key1"); ok {
+		t.Errorf("expected key1 to be expired")
+	}
+	if !eventually(func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return expired["key1"] == 19 && expired["key2"] == 23
+	}) {
+		t.Errorf("expected callback for key1 and key2, got %v", expired)
+	}
+}
+
+func TestTimedMapGetOrPut(t *testing.T) {
+	tm := New[string, int](time.Minute)
+	actual, loaded := tm.GetOrPut("key", 19, time.Second)
+	if loaded || actual != 19 {
+		t.Errorf("expected value 19 to be added, got %d (loaded %t)", actual, loaded)
+	}
+	actual, loaded = tm.GetOrPut("key", 23, time.Second)
+	if !loaded || actual != 19 {
+		t.Errorf("expected value 19 to be loaded, got %d (loaded %t)", actual, loaded)
+	}
+	tm.Put("expired", 19, -time.Second)
+	actual, loaded = tm.GetOrPut("expired", 23, time.Second)
+	if loaded || actual != 23 {
+		t.Errorf("expected expired value to be replaced with 23, got %d (loaded %t)", actual, loaded)
+	}
+}
+
+func TestTimedMapGetOrCompute(t *testing.T) {
+	tm := New[string, int](time.Minute)
+	calls := 0
+	f := func() int {
+		calls++
+		return 19
+	}
+	value, loaded := tm.GetOrCompute("key", time.Second, f)
+	if loaded || value != 19 {
+		t.Errorf("expected value 19 to be computed, got %d (loaded %t)", value, loaded)
+	}
+	value, loaded = tm.GetOrCompute("key", time.Second, f)
+	if !loaded || value != 19 {
+		t.Errorf("expected value 19 to be loaded, got %d (loaded %t)", value, loaded)
+	}
+	if calls != 1 {
+		t.Errorf("expected f to be called once, got %d", calls)
+	}
+}
+
+func TestTimedMapTTL(t *testing.T) {
+	tm := New[string, int](time.Minute)
+	tm.Put("key", 19, time.Second)
+	ttl, ok := tm.TTL("key")
+	if !ok || ttl <= 0 || ttl > time.Second {
+		t.Errorf("expected ttl in (0, 1s], got %v (ok %t)", ttl, ok)
+	}
+	tm.Put("expired", 23, -time.Second)
+	if ttl, ok := tm.TTL("expired"); ok || ttl != 0 {
+		t.Errorf("expected 0 and false for expired key, got %v (ok %t)", ttl, ok)
+	}
+	if ttl, ok := tm.TTL("non-existent-key"); ok || ttl != 0 {
+		t.Errorf("expected 0 and false for non-existent key, got %v (ok %t)", ttl, ok)
+	}
+}
+
+func TestTimedMapRefresh(t *testing.T) {
+	clock := newFakeClock()
+	tm := NewWithClock[string, int](time.Minute, clock)
+	tm.Put("key", 19, 100*time.Millisecond)
+	if !tm.Refresh("key", time.Second) {
+		t.Errorf("expected key to be refreshed")
+	}
+	clock.Advance(200 * time.Millisecond)
+	if value, ok := tm.Get("key"); !ok || value != 19 {
+		t.Errorf("expected value 19, got %d", value)
+	}
+	tm.Put("expired", 23, -time.Second)
+	if tm.Refresh("expired", time.Second) {
+		t.Errorf("expected expired key not to be refreshed")
+	}
+	if tm.Refresh("non-existent-key", time.Second) {
+		t.Errorf("expected non-existent key not to be refreshed")
+	}
+}
+
+func TestTimedMapKeys(t *testing.T) {
+	tm := New[string, int](time.Minute)
+	tm.Put("key1", 19, time.Second)
+	tm.Put("key2", 23, time.Second)
+	tm.Put("expired", 29, -time.Second)
+	keys := tm.Keys()
+	slices.Sort(keys)
+	if !slices.Equal(keys, []string{"key1", "key2"}) {
+		t.Errorf("expected keys [key1 key2], got %v", keys)
+	}
+	if tm.Size() != 3 {
+		t.Errorf("expected Keys not to remove expired entries, got size %d", tm.Size())
+	}
+}
+
+func TestTimedMapValues(t *testing.T) {
+	tm := New[string, int](time.Minute)
+	tm.Put("key1", 19, time.Second)
+	tm.Put("key2", 23, time.Second)
+	tm.Put("expired", 29, -time.Second)
+	values := tm.Values()
+	slices.Sort(values)
+	if !slices.Equal(values, []int{19, 23}) {
+		t.Errorf("expected values [19 23], got %v", values)
+	}
+}
+
+func TestTimedMapItems(t *testing.T) {
+	tm := New[string, int](time.Minute)
+	tm.Put("key", 19, time.Second)
+	tm.Put("expired", 23, -time.Second)
+	items := tm.Items()
+	if len(items) != 1 {
+		t.Fatalf("expected 1 item, got %d", len(items))
+	}
+	item := items[0]
+	if item.Key != "key" || item.Value != 19 {
+		t.Errorf("expected key with value 19, got %s with value %d", item.Key, item.Value)
+	}
+	if remaining := time.Until(item.Expiration); remaining <= 0 || remaining > time.Second {
+		t.Errorf("expected expiration within 1s, got %v", remaining)
+	}
+}
+
+func TestTimedMapRange(t *testing.T) {
+	tm := New[string, int](time.Minute)
+	tm.Put("key1", 19, time.Second)
+	tm.Put("key2", 23, time.Second)
+	tm.Put("expired", 29, -time.Second)
+	seen := make(map[string]int)
+	tm.Range(func(key string, value int) bool {
+		seen[key] = value
+		return true
+	})
+	if len(seen) != 2 || seen["key1"] != 19 || seen["key2"] != 23 {
+		t.Errorf("expected key1 and key2 to be visited, got %v", seen)
+	}
+	calls := 0
+	tm.Range(func(key string, value int) bool {
+		calls++
+		return false
+	})
+	if calls != 1 {
+		t.Errorf("expected Range to stop after 1 call, got %d", calls)
+	}
+}
+
+func TestTimedMapAll(t *testing.T) {
+	tm := New[string, int](time.Minute)
+	tm.Put("key1", 19, time.Second)
+	tm.Put("key2", 23, time.Second)
+	tm.Put("expired", 29, -time.Second)
+	seen := make(map[string]int)
+	for key, value := range tm.All() {
+		seen[key] = value
+		// The loop body runs without the lock held.
+		tm.Put("other", value, time.Second)
+	}
+	if len(seen) != 2 || seen["key1"] != 19 || seen["key2"] != 23 {
+		t.Errorf("expected key1 and key2 to be visited, got %v", seen)
+	}
+	calls := 0
+	for range tm.All() {
+		calls++
+		break
+	}
+	if calls != 1 {
+		t.Errorf("expected iteration to stop after 1 entry, got %d", calls)
+	}
+}
+
+func TestTimedMapPutPermanent(t *testing.T) {
+	clock := newFakeClock()
+	tm := NewWithClock[string, int](0, clock)
+	tm.PutPermanent("key", 19)
+	clock.Advance(100 * 365 * 24 * time.Hour)
+	tm.CleanupNow()
+	if value, ok := tm.Get("key"); !ok || value != 19 {
+		t.Errorf("expected permanent value 19, got %d", value)
+	}
+	if ttl, ok := tm.TTL("key"); !ok || ttl != NoExpiration {
+		t.Errorf("expected NoExpiration, got %v (ok %t)", ttl, ok)
+	}
+	if !tm.Refresh("key", time.Second) {
+		t.Errorf("expected permanent key to be refreshed")
+	}
+	if ttl, ok := tm.TTL("key"); !ok || ttl <= 0 {
+		t.Errorf("expected refreshed key to expire, got %v (ok %t)", ttl, ok)
+	}
+}
+
+func TestTimedMapNonPositiveTTL(t *testing.T) {
+	for _, ttl := range []time.Duration{0, -time.Second} {
+		tm := New[string, int](time.Millisecond)
+		tm.Put("key", 19, ttl)
+		if _, ok := tm.Get("key"); ok {
+			t.Errorf("expected key with ttl %v to be expired", ttl)
+		}
+		if _, ok := tm.TTL("key"); ok {
+			t.Errorf("expected no TTL for key with ttl %v", ttl)
+		}
+		tm.Put("key", 19, ttl)
+		if !eventually(func() bool { return tm.Size() == 0 }) {
+			t.Errorf("expected key with ttl %v to be cleaned up, got size %d", ttl, tm.Size())
+		}
+		tm.Stop()
+	}
+}
+
+func TestTimedMapPeek(t *testing.T) {
+	tm := New[string, int](time.Minute)
+	tm.Put("key", 19, time.Second)
+	tm.Put("expired", 23, -time.Second)
+	if value, expired, ok := tm.Peek("key"); !ok || expired || value != 19 {
+		t.Errorf("expected live value 19, got %d (expired %t, ok %t)", value, expired, ok)
+	}
+	if value, expired, ok := tm.Peek("expired"); !ok || !expired || value != 23 {
+		t.Errorf("expected expired value 23, got %d (expired %t, ok %t)", value, expired, ok)
+	}
+	if tm.Size() != 2 {
+		t.Errorf("expected Peek not to remove expired entries, got size %d", tm.Size())
+	}
+	if _, _, ok := tm.Peek("non-existent-key"); ok {
+		t.Errorf("expected ok to be false")
+	}
+}
+
+func TestTimedMapPutAll(t *testing.T) {
+	tm := New[string, int](time.Minute)
+	tm.PutAll(map[string]int{"key1": 19, "key2": 23}, time.Second)
+	if tm.Size() != 2 {
+		t.Errorf("expected size 2, got %d", tm.Size())
+	}
+	if value, ok := tm.Get("key2"); !ok || value != 23 {
+		t.Errorf("expected value 23, got %d", value)
+	}
+}
+
+func TestTimedMapGetAll(t *testing.T) {
+	tm := New[string, int](time.Minute)
+	tm.Put("key1", 19, time.Second)
+	tm.Put("key2", 23, time.Second)
+	tm.Put("expired", 29, -time.Second)
+	values := tm.GetAll([]string{"key1", "key2", "expired", "non-existent-key"})
+	if !maps.Equal(values, map[string]int{"key1": 19, "key2": 23}) {
+		t.Errorf("expected key1 and key2, got %v", values)
+	}
+}
+
+func TestTimedMapDeleteAll(t *testing.T) {
+	tm := New[string, int](time.Minute)
+	tm.Put("key1", 19, time.Second)
+	tm.Put("key2", 23, time.Second)
+	tm.Put("key3", 29, time.Second)
+	if removed := tm.DeleteAll("key1", "key2", "non-existent-key"); removed != 2 {
+		t.Errorf("expected 2 entries to be removed, got %d", removed)
+	}
+	if tm.Size() != 1 || !tm.Contains("key3") {
+		t.Errorf("expected only key3 to remain, got size %d", tm.Size())
+	}
+}
+
+func TestTimedMapCapacity(t *testing.T) {
+	tm := NewWithCapacity[string, int](time.Minute, 2)
+	var evicted []string
+	tm.OnExpire(func(key string, value int) {
+		t.Errorf("expected no expiration callback for evicted key %s", key)
+	})
+	tm.OnEvict(func(key string, value int) {
+		evicted = append(evicted, key)
+	})
+	tm.Put("key1", 19, time.Second)
+	tm.Put("key2", 23, time.Second)
+	tm.Get("key1")
+	tm.Put("key3", 29, time.Second)
+	if tm.Size() != 2 {
+		t.Errorf("expected size 2, got %d", tm.Size())
+	}
+	if tm.Contains("key2") {
+		t.Errorf("expected least recently used key2 to be evicted")
+	}
+	if !tm.Contains("key1") || !tm.Contains("key3") {
+		t.Errorf("expected key1 and key3 to be present")
+	}
+	if !slices.Equal(evicted, []string{"key2"}) {
+		t.Errorf("expected callback for key2, got %v", evicted)
+	}
+	tm.Put("key3", 31, time.Second)
+	if tm.Size() != 2 || !tm.Contains("key1") {
+		t.Errorf("expected overwriting key3 not to evict key1")
+	}
+}
+
+func TestTimedMapStats(t *testing.T) {
+	tm := NewWithCapacity[string, int](time.Minute, 2)
+	tm.Put("key", 19, time.Second)
+	tm.Put("expired", 23, -time.Second)
+	tm.Get("key")
+	tm.Get("key")
+	tm.Get("expired")
+	tm.Get("non-existent-key")
+	tm.Put("key2", 29, time.Second)
+	tm.Put("key3", 31, time.Second)
+	expected := Stats{Hits: 2, Misses: 2, Expirations: 1, Evictions: 1}
+	if stats := tm.Stats(); stats != expected {
+		t.Errorf("expected %+v, got %+v", expected, stats)
+	}
+}
+
+func TestTimedMapStatsLookups(t *testing.T) {
+	tm := New[string, int](0)
+	tm.Put("key1", 19, time.Minute)
+	tm.Put("key2", 23, time.Minute)
+	tm.GetOrCompute("key1", time.Minute, func() int { return 0 })
+	tm.GetOrCompute("key3", time.Minute, func() int { return 29 })
+	tm.GetOrComputeOnce("key1", time.Minute, func() (int, error) { return 0, nil })
+	tm.GetAll([]string{"key1", "missing"})
+	tm.GetAndDelete("key2")
+	tm.Transaction(func(txn *Txn[string, int]) {
+		txn.Get("key2")
+	})
+	if stats := tm.Stats(); stats.Hits != 4 || stats.Misses != 3 {
+		t.Errorf("expected 4 hits and 3 misses, got %d and %d", stats.Hits, stats.Misses)
+	}
+}
+
+// eventually polls cond until it returns true or a second has passed, and reports whether it returned true.
+// It lets tests wait for the background cleanup without sleeping for a fixed duration.
+func eventually(cond func() bool) bool {
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return true
+}
+
+// fakeClock is a [Clock] whose time only moves when it is advanced.
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Now()}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func TestTimedMapClock(t *testing.T) {
+	clock := newFakeClock()
+	tm := NewWithClock[string, int](time.Minute, clock)
+	tm.Put("key", 19, time.Hour)
+	clock.Advance(59 * time.Minute)
+	if value, ok := tm.Get("key"); !ok || value != 19 {
+		t.Errorf("expected value 19, got %d", value)
+	}
+	clock.Advance(time.Minute)
+	if _, ok := tm.Get("key"); ok {
+		t.Errorf("expected key to be expired")
+	}
+}
+
+func TestTimedMapUpdate(t *testing.T) {
+	clock := newFakeClock()
+	tm := NewWithClock[string, int](time.Minute, clock)
+	tm.Put("key", 19, time.Second)
+	clock.Advance(500 * time.Millisecond)
+	if !tm.Update("key", func(old int) int { return old + 4 }) {
+		t.Errorf("expected key to be updated")
+	}
+	if value, _ := tm.Get("key"); value != 23 {
+		t.Errorf("expected value 23, got %d", value)
+	}
+	if ttl, _ := tm.TTL("key"); ttl != 500*time.Millisecond {
+		t.Errorf("expected expiration to be kept, got ttl %v", ttl)
+	}
+	if tm.Update("non-existent-key", func(old int) int { return old + 4 }) {
+		t.Errorf("expected non-existent key not to be updated")
+	}
+}
+
+func TestTimedMapSetTTL(t *testing.T) {
+	clock := newFakeClock()
+	tm := NewWithClock[string, int](time.Minute, clock)
+	tm.Put("key", 19, time.Second)
+	if !tm.SetTTL("key", time.Hour) {
+		t.Errorf("expected ttl to be set")
+	}
+	if ttl, _ := tm.TTL("key"); ttl != time.Hour {
+		t.Errorf("expected ttl 1h, got %v", ttl)
+	}
+	if !tm.SetExpiration("key", clock.Now().Add(time.Minute)) {
+		t.Errorf("expected expiration to be set")
+	}
+	clock.Advance(time.Minute)
+	if _, ok := tm.Get("key"); ok {
+		t.Errorf("expected key to be expired")
+	}
+	if tm.SetTTL("key", time.Hour) || tm.SetExpiration("key", clock.Now().Add(time.Hour)) {
+		t.Errorf("expected expired key not to be updated")
+	}
+}
+
+func TestTimedMapSnapshotRestore(t *testing.T) {
+	clock := newFakeClock()
+	tm := NewWithClock[string, int](time.Minute, clock)
+	tm.Put("key", 19, time.Hour)
+	tm.PutPermanent("permanent", 23)
+	tm.Put("expired", 29, -time.Second)
+	snapshot := tm.Snapshot()
+	if len(snapshot) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(snapshot))
+	}
+	clock.Advance(30 * time.Minute)
+	restored := NewWithClock[string, int](time.Minute, clock)
+	restored.Restore(append(snapshot,
+		Entry[string, int]{Key: "stale", Value: 31},
+		Entry[string, int]{Key: "just-expired", Value: 37, TTL: -time.Nanosecond},
+	))
+	if restored.Size() != 2 {
+		t.Errorf("expected size 2, got %d", restored.Size())
+	}
+	if ttl, ok := restored.TTL("key"); !ok || ttl != time.Hour {
+		t.Errorf("expected ttl 1h relative to restore, got %v", ttl)
+	}
+	if ttl, ok := restored.TTL("permanent"); !ok || ttl != NoExpiration {
+		t.Errorf("expected permanent entry, got ttl %v", ttl)
+	}
+}
+
+func TestTimedMapSetCleanupInterval(t *testing.T) {
+	clock := newFakeClock()
+	tm := NewWithClock[string, int](time.Hour, clock)
+	defer tm.Stop()
+	if interval := tm.CleanupInterval(); interval != time.Hour {
+		t.Errorf("expected interval of 1h, got %v", interval)
+	}
+	tm.SetCleanupInterval(time.Millisecond)
+	if interval := tm.CleanupInterval(); interval != time.Millisecond {
+		t.Errorf("expected interval of 1ms, got %v", interval)
+	}
+	tm.Put("key", 19, 10*time.Millisecond)
+	clock.Advance(10 * time.Millisecond)
+	if !eventually(func() bool { return tm.Size() == 0 }) {
+		t.Errorf("expected key to be cleaned up, got size %d", tm.Size())
+	}
+	tm.SetCleanupInterval(0)
+	tm.Put("key", 19, 10*time.Millisecond)
+	// Let a sweep that was already under way finish before the key expires,
+	// then give a paused cleanup the time of several intervals to wrongly remove it.
+	time.Sleep(10 * time.Millisecond)
+	clock.Advance(10 * time.Millisecond)
+	time.Sleep(10 * time.Millisecond)
+	if tm.Size() != 1 {
+		t.Errorf("expected cleanup to be paused, got size %d", tm.Size())
+	}
+}
+
+func TestTimedMapPauseCleanup(t *testing.T) {
+	clock := newFakeClock()
+	tm := NewWithClock[string, int](time.Millisecond, clock)
+	defer tm.Stop()
+	tm.PauseCleanup()
+	tm.Put("key", 19, 10*time.Millisecond)
+	clock.Advance(10 * time.Millisecond)
+	// Give the paused cleanup the time of several ticks to wrongly remove the key.
+	time.Sleep(10 * time.Millisecond)
+	if tm.Size() != 1 {
+		t.Errorf("expected cleanup to be paused, got size %d", tm.Size())
+	}
+	select {
+	case <-tm.exited:
+		t.Fatalf("expected the cleanup goroutine to keep running")
+	default:
+	}
+	tm.ResumeCleanup()
+	if !eventually(func() bool { return tm.Size() == 0 }) {
+		t.Errorf("expected key to be cleaned up once resumed, got size %d", tm.Size())
+	}
+}
+
+const benchmarkKeys = 1 << 16
+
+func BenchmarkPut(b *testing.B) {
+	tm := New[int, int](time.Minute)
+	defer tm.Stop()
+	for i := range b.N {
+		tm.Put(i%benchmarkKeys, i, time.Minute)
+	}
+}
+
+func BenchmarkLoad(b *testing.B) {
+	b.Run("Default", func(b *testing.B) {
+		for range b.N {
+			tm := NewLazy[int, int]()
+			for i := range benchmarkKeys {
+				tm.Put(i, i, time.Minute)
+			}
+		}
+	})
+	b.Run("CapacityHint", func(b *testing.B) {
+		for range b.N {
+			tm := NewWithCapacityHint[int, int](0, benchmarkKeys)
+			for i := range benchmarkKeys {
+				tm.Put(i, i, time.Minute)
+			}
+		}
+	})
+}
+
+func BenchmarkGet(b *testing.B) {
+	tm := New[int, int](time.Minute)
+	defer tm.Stop()
+	for i := range benchmarkKeys {
+		tm.Put(i, i, time.Hour)
+	}
+	b.Run("Hit", func(b *testing.B) {
+		for i := range b.N {
+			tm.Get(i % benchmarkKeys)
+		}
+	})
+	b.Run("Miss", func(b *testing.B) {
+		for i := range b.N {
+			tm.Get(benchmarkKeys + i%benchmarkKeys)
+		}
+	})
+}
+
+func BenchmarkGetExpired(b *testing.B) {
+	tm := New[int, int](time.Minute)
+	defer tm.Stop()
+	for i := range b.N {
+		b.StopTimer()
+		tm.Put(i, i, -time.Second)
+		b.StartTimer()
+		tm.Get(i)
+	}
+}
+
+func BenchmarkConcurrentGetPut(b *testing.B) {
+	tm := New[int, int](time.Minute)
+	defer tm.Stop()
+	for i := range benchmarkKeys {
+		tm.Put(i, i, time.Hour)
+	}
+	b.RunParallel(func(pb *testing.PB) {
+		for i := 0; pb.Next(); i++ {
+			key := rand.IntN(benchmarkKeys)
+			if i%10 == 0 {
+				tm.Put(key, i, time.Hour)
+			} else {
+				tm.Get(key)
+			}
+		}
+	})
+}
+
+func TestTimedMapGetAndDelete(t *testing.T) {
+	tm := New[string, int](time.Minute)
+	tm.Put("key", 19, time.Second)
+	tm.Put("expired", 23, -time.Second)
+	if value, ok := tm.GetAndDelete("key"); !ok || value != 19 {
+		t.Errorf("expected value 19, got %d", value)
+	}
+	if _, ok := tm.GetAndDelete("key"); ok {
+		t.Errorf("expected key to be deleted")
+	}
+	if _, ok := tm.GetAndDelete("expired"); ok {
+		t.Errorf("expected expired key not to be returned")
+	}
+	if tm.Size() != 0 {
+		t.Errorf("expected size 0, got %d", tm.Size())
+	}
+}
+
+func TestTimedMapGetAndRefresh(t *testing.T) {
+	clock := newFakeClock()
+	tm := NewWithClock[string, int](time.Minute, clock)
+	tm.Put("key", 19, time.Second)
+	clock.Advance(500 * time.Millisecond)
+	if value, ok := tm.GetAndRefresh("key", time.Second); !ok || value != 19 {
+		t.Errorf("expected value 19, got %d", value)
+	}
+	clock.Advance(800 * time.Millisecond)
+	if value, ok := tm.Get("key"); !ok || value != 19 {
+		t.Errorf("expected refreshed key to be present, got %d", value)
+	}
+	clock.Advance(time.Second)
+	if _, ok := tm.GetAndRefresh("key", time.Second); ok {
+		t.Errorf("expected expired key not to be refreshed")
+	}
+}
+
+func TestTimedMapPutDefault(t *testing.T) {
+	tm := NewWithDefaultTTL[string, int](time.Minute, time.Hour)
+	tm.PutDefault("key", 19)
+	if ttl, ok := tm.TTL("key"); !ok || ttl <= 59*time.Minute {
+		t.Errorf("expected default ttl of 1h, got %v", ttl)
+	}
+	tm.Put("key", 23, time.Second)
+	if ttl, ok := tm.TTL("key"); !ok || ttl > time.Second {
+		t.Errorf("expected ttl to be overridden, got %v", ttl)
+	}
+	unset := New[string, int](0)
+	unset.PutDefault("key", 19)
+	if ttl, ok := unset.TTL("key"); !ok || ttl != NoExpiration {
+		t.Errorf("expected an entry that never expires without a default ttl, got %v (ok %t)", ttl, ok)
+	}
+}
+
+func TestTimedMapSwap(t *testing.T) {
+	tm := New[string, int](time.Minute)
+	if _, existed := tm.Swap("key", 19, time.Second); existed {
+		t.Errorf("expected key not to exist")
+	}
+	if old, existed := tm.Swap("key", 23, time.Second); !existed || old != 19 {
+		t.Errorf("expected previous value 19, got %d", old)
+	}
+	if value, _ := tm.Get("key"); value != 23 {
+		t.Errorf("expected value 23, got %d", value)
+	}
+	tm.Put("expired", 29, -time.Second)
+	if _, existed := tm.Swap("expired", 31, time.Second); existed {
+		t.Errorf("expected expired key not to be reported")
+	}
+}
+
+func TestTimedMapCompareAndDelete(t *testing.T) {
+	tm := New[string, int](time.Minute)
+	tm.Put("key", 19, time.Second)
+	if tm.CompareAndDelete("key", 23) {
+		t.Errorf("expected key not to be deleted for a different value")
+	}
+	if !tm.CompareAndDelete("key", 19) {
+		t.Errorf("expected key to be deleted")
+	}
+	if tm.Contains("key") {
+		t.Errorf("expected key to be removed")
+	}
+}
+
+func TestTimedMapCompareAndDeleteNonComparable(t *testing.T) {
+	tm := New[string, []int](time.Minute)
+	tm.Put("key", []int{19}, time.Second)
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected panic for non-comparable value type")
+		}
+	}()
+	tm.CompareAndDelete("key", []int{19})
+}
+
+func TestTimedMapCompareAndSwap(t *testing.T) {
+	clock := newFakeClock()
+	tm := NewWithClock[string, int](time.Minute, clock)
+	tm.Put("key", 19, time.Second)
+	if tm.CompareAndSwap("key", 23, 29, time.Hour) {
+		t.Errorf("expected value not to be swapped for a different old value")
+	}
+	if !tm.CompareAndSwap("key", 19, 29, time.Hour) {
+		t.Errorf("expected value to be swapped")
+	}
+	if value, _ := tm.Get("key"); value != 29 {
+		t.Errorf("expected value 29, got %d", value)
+	}
+	if ttl, _ := tm.TTL("key"); ttl != time.Hour {
+		t.Errorf("expected ttl to be reset to 1h, got %v", ttl)
+	}
+}
+
+func TestTimedMapLiveSize(t *testing.T) {
+	tm := New[string, int](time.Minute)
+	tm.Put("key1", 19, time.Second)
+	tm.Put("key2", 23, time.Second)
+	tm.Put("expired", 29, -time.Second)
+	if size := tm.LiveSize(); size != 2 {
+		t.Errorf("expected live size 2, got %d", size)
+	}
+}
+
+func TestTimedMapExpirationBounds(t *testing.T) {
+	clock := newFakeClock()
+	tm := NewWithClock[string, int](0, clock)
+	tm.PutPermanent("permanent", 17)
+	if _, _, ok := tm.ExpirationBounds(); ok {
+		t.Errorf("expected no bounds without expiring entries")
+	}
+	now := clock.Now()
+	tm.Put("key1", 19, time.Second)
+	tm.Put("key2", 23, time.Hour)
+	tm.Put("key3", 29, time.Minute)
+	clock.Advance(time.Second)
+	earliest, latest, ok := tm.ExpirationBounds()
+	if !ok || !earliest.Equal(now.Add(time.Minute)) || !latest.Equal(now.Add(time.Hour)) {
+		t.Errorf("expected bounds of 1m and 1h, got %v and %v", earliest.Sub(now), latest.Sub(now))
+	}
+}
+
+func TestTimedMapCount(t *testing.T) {
+	tm := New[string, int](0)
+	tm.Put("key1", 19, time.Second)
+	tm.Put("key2", 23, time.Second)
+	tm.Put("key3", 30, time.Second)
+	tm.Put("expired", 29, -time.Second)
+	tm.PutTombstone("tombstone", time.Second)
+	odd := func(key string, value int) bool {
+		return value%2 == 1
+	}
+	if count := tm.Count(odd); count != 2 {
+		t.Errorf("expected 2 odd values, got %d", count)
+	}
+}
+
+func TestTimedMapSizeIncludesUnsweptExpiredEntries(t *testing.T) {
+	tm := New[string, int](time.Minute)
+	tm.Put("key", 19, time.Second)
+	tm.Put("expired", 23, -time.Second)
+	if tm.Size() != 2 {
+		t.Errorf("expected Size to include the unswept expired entry, got %d", tm.Size())
+	}
+	if tm.LiveSize() != 1 {
+		t.Errorf("expected LiveSize to exclude the expired entry, got %d", tm.LiveSize())
+	}
+	tm.Get("expired")
+	if tm.Size() != 1 {
+		t.Errorf("expected Size to drop the lazily removed entry, got %d", tm.Size())
+	}
+}
+
+func TestTimedMapForEach(t *testing.T) {
+	tm := New[string, int](time.Minute)
+	var expired []string
+	tm.OnExpire(func(key string, value int) {
+		expired = append(expired, key)
+	})
+	tm.Put("key1", 19, time.Second)
+	tm.Put("key2", 23, time.Second)
+	tm.Put("expired", 29, -time.Second)
+	seen := make(map[string]int)
+	tm.ForEach(func(key string, value int) {
+		seen[key] = value
+	})
+	if !maps.Equal(seen, map[string]int{"key1": 19, "key2": 23}) {
+		t.Errorf("expected key1 and key2 to be visited, got %v", seen)
+	}
+	if tm.Size() != 2 || !slices.Equal(expired, []string{"expired"}) {
+		t.Errorf("expected expired entry to be removed, got size %d and callbacks %v", tm.Size(), expired)
+	}
+}
+
+func TestTimedMapCleanupNow(t *testing.T) {
+	clock := newFakeClock()
+	tm := NewWithClock[string, int](time.Hour, clock)
+	tm.Put("key1", 19, time.Second)
+	tm.Put("key2", 23, time.Minute)
+	tm.Put("key3", 29, time.Second)
+	clock.Advance(time.Second)
+	if removed := tm.CleanupNow(); removed != 2 {
+		t.Errorf("expected 2 entries to be removed, got %d", removed)
+	}
+	if tm.Size() != 1 || !tm.Contains("key2") {
+		t.Errorf("expected only key2 to remain, got size %d", tm.Size())
+	}
+}
+
+func TestTimedMapNewLazy(t *testing.T) {
+	if NewLazy[string, int]().t != nil {
+		t.Errorf("expected no cleanup ticker")
+	}
+	clock := newFakeClock()
+	tm := NewWithClock[string, int](0, clock)
+	tm.Put("key", 19, time.Millisecond)
+	clock.Advance(time.Millisecond)
+	if tm.Size() != 1 {
+		t.Errorf("expected expired key not to be swept, got size %d", tm.Size())
+	}
+	if _, ok := tm.Get("key"); ok || tm.Size() != 0 {
+		t.Errorf("expected expired key to be removed lazily")
+	}
+	tm.SetCleanupInterval(time.Millisecond)
+	defer tm.Stop()
+	tm.Put("key", 19, time.Millisecond)
+	clock.Advance(time.Millisecond)
+	if !eventually(func() bool { return tm.Size() == 0 }) {
+		t.Errorf("expected cleanup to start after setting a positive interval, got size %d", tm.Size())
+	}
+}
+
+func TestTimedMapHits(t *testing.T) {
+	tm := New[string, int](time.Minute)
+	tm.Put("key", 19, time.Second)
+	if hits, ok := tm.Hits("key"); !ok || hits != 0 {
+		t.Errorf("expected 0 hits, got %d", hits)
+	}
+	tm.Get("key")
+	tm.Get("key")
+	if hits, ok := tm.Hits("key"); !ok || hits != 2 {
+		t.Errorf("expected 2 hits, got %d", hits)
+	}
+	tm.Put("key", 23, time.Second)
+	if hits, _ := tm.Hits("key"); hits != 0 {
+		t.Errorf("expected hits to reset when the entry is replaced, got %d", hits)
+	}
+	if _, ok := tm.Hits("non-existent-key"); ok {
+		t.Errorf("expected ok to be false")
+	}
+}
+
+func TestTimedMapCallbackPanic(t *testing.T) {
+	tm := New[string, int](time.Millisecond)
+	defer tm.Stop()
+	errs := make(chan error, 10)
+	tm.OnError(func(err error) {
+		errs <- err
+	})
+	tm.OnExpire(func(key string, value int) {
+		panic("boom")
+	})
+	tm.Put("key1", 19, time.Millisecond)
+	select {
+	case err := <-errs:
+		var callbackErr *CallbackError
+		if !errors.As(err, &callbackErr) || callbackErr.Value != "boom" {
+			t.Errorf("expected CallbackError with value boom, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("expected the panic to be reported")
+	}
+	// The cleanup goroutine must survive the panic and keep sweeping.
+	tm.OnExpire(nil)
+	tm.Put("key2", 23, time.Millisecond)
+	if !eventually(func() bool { return tm.Size() == 0 }) {
+		t.Errorf("expected cleanup to keep running after a panic, got size %d", tm.Size())
+	}
+}
+
+func TestTimedMapClone(t *testing.T) {
+	tm := NewWithCapacity[string, int](time.Minute, 3)
+	tm.Put("key1", 19, time.Second)
+	tm.Put("key2", 23, time.Second)
+	tm.Put("expired", 29, -time.Second)
+	tm.Get("key1")
+	clone := tm.Clone()
+	defer clone.Stop()
+	if clone.Size() != 2 {
+		t.Errorf("expected clone size 2, got %d", clone.Size())
+	}
+	clone.Put("key1", 31, time.Second)
+	if value, _ := tm.Get("key1"); value != 19 {
+		t.Errorf("expected original to be unaffected, got %d", value)
+	}
+	expiration, _ := tm.TTL("key2")
+	if ttl, _ := clone.TTL("key2"); ttl > expiration {
+		t.Errorf("expected expiration to be preserved, got %v", ttl)
+	}
+	clone.Put("key3", 37, time.Second)
+	clone.Put("key4", 41, time.Second)
+	if !clone.Contains("key1") || clone.Contains("key2") {
+		t.Errorf("expected clone to keep the LRU order and evict key2")
+	}
+}
+
+func TestTimedMapMerge(t *testing.T) {
+	clock := newFakeClock()
+	tm := NewWithClock[string, int](time.Minute, clock)
+	other := NewWithClock[string, int](time.Minute, clock)
+	tm.Put("key1", 19, time.Hour)
+	other.Put("key1", 23, time.Minute)
+	tm.Put("key2", 29, time.Minute)
+	other.Put("key2", 31, time.Hour)
+	other.PutPermanent("key3", 37)
+	other.Put("expired", 41, -time.Second)
+	tm.Merge(other)
+	expected := map[string]int{"key1": 19, "key2": 31, "key3": 37}
+	if values := tm.GetAll([]string{"key1", "key2", "key3", "expired"}); !maps.Equal(values, expected) {
+		t.Errorf("expected %v, got %v", expected, values)
+	}
+	if ttl, _ := tm.TTL("key2"); ttl != time.Hour {
+		t.Errorf("expected merged expiration to be preserved, got %v", ttl)
+	}
+}
+
+func TestTimedMapMergeConcurrently(t *testing.T) {
+	a := New[int, int](time.Minute)
+	b := New[int, int](time.Minute)
+	for i := range 100 {
+		a.Put(i, i, time.Second)
+		b.Put(i+100, i, time.Second)
+	}
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			a.Merge(b)
+		}()
+		go func() {
+			defer wg.Done()
+			b.Merge(a)
+		}()
+	}
+	wg.Wait()
+	if a.Size() != 200 || b.Size() != 200 {
+		t.Errorf("expected both maps to hold 200 entries, got %d and %d", a.Size(), b.Size())
+	}
+}
+
+func TestTimedMapFilter(t *testing.T) {
+	tm := New[string, int](time.Minute)
+	tm.Put("key1", 19, time.Second)
+	tm.Put("key2", 23, time.Second)
+	tm.Put("key3", 29, time.Second)
+	removed := tm.Filter(func(key string, value int) bool {
+		return value != 23
+	})
+	if removed != 1 {
+		t.Errorf("expected 1 entry to be removed, got %d", removed)
+	}
+	if tm.Contains("key2") || !tm.Contains("key1") || !tm.Contains("key3") {
+		t.Errorf("expected only key2 to be removed")
+	}
+}
+
+func TestTimedMapSlidingExpiration(t *testing.T) {
+	if NewWithExpirationPolicy[string, int](0, Sliding).policy != Sliding {
+		t.Errorf("expected NewWithExpirationPolicy to set the sliding policy")
+	}
+	clock := newFakeClock()
+	tm := NewWithOptions(
+		WithCleanupInterval[string, int](0),
+		WithClock[string, int](clock),
+		WithExpirationPolicy[string, int](Sliding),
+	)
+	tm.Put("key", 19, time.Second)
+	for range 3 {
+		clock.Advance(800 * time.Millisecond)
+		if _, ok := tm.Get("key"); !ok {
+			t.Fatalf("expected key to be kept alive by Get")
+		}
+	}
+	clock.Advance(time.Second)
+	if _, ok := tm.Get("key"); ok {
+		t.Errorf("expected idle key to be expired")
+	}
+}
+
+func TestTimedMapSlidingWindowPreserved(t *testing.T) {
+	clock := newFakeClock()
+	sliding := func() *TimedMap[string, int] {
+		return NewWithOptions(
+			WithCleanupInterval[string, int](0),
+			WithClock[string, int](clock),
+			WithExpirationPolicy[string, int](Sliding),
+		)
+	}
+	tm := sliding()
+	tm.Put("key", 19, 10*time.Minute)
+	clock.Advance(9 * time.Minute)
+	clone := tm.Clone()
+	merged := sliding()
+	merged.Merge(tm)
+	for name, m := range map[string]*TimedMap[string, int]{"clone": clone, "merged": merged} {
+		m.Get("key")
+		if ttl, _ := m.TTL("key"); ttl != 10*time.Minute {
+			t.Errorf("expected the %s entry to keep its 10m window, got %v", name, ttl)
+		}
+	}
+	tm.ExtendAll(time.Hour)
+	tm.Get("key")
+	if ttl, _ := tm.TTL("key"); ttl != 70*time.Minute {
+		t.Errorf("expected ExtendAll to widen the window to 70m, got %v", ttl)
+	}
+}
+
+func TestTimedMapAbsoluteExpiration(t *testing.T) {
+	clock := newFakeClock()
+	tm := NewWithClock[string, int](time.Minute, clock)
+	tm.Put("key", 19, time.Second)
+	clock.Advance(800 * time.Millisecond)
+	tm.Get("key")
+	clock.Advance(800 * time.Millisecond)
+	if _, ok := tm.Get("key"); ok {
+		t.Errorf("expected key to expire regardless of access")
+	}
+}
+
+func TestTimedMapTombstone(t *testing.T) {
+	clock := newFakeClock()
+	tm := NewWithClock[string, int](time.Minute, clock)
+	tm.Put("key", 19, time.Second)
+	tm.PutTombstone("key", time.Second)
+	if _, state := tm.GetWithState("key"); state != NegativeCached {
+		t.Errorf("expected key to be negative cached, got %v", state)
+	}
+	if _, ok := tm.Get("key"); ok || tm.Contains("key") {
+		t.Errorf("expected tombstone to be reported as missing")
+	}
+	if _, state := tm.GetWithState("other"); state != Missing {
+		t.Errorf("expected other to be missing, got %v", state)
+	}
+	clock.Advance(time.Second)
+	if _, state := tm.GetWithState("key"); state != Missing {
+		t.Errorf("expected tombstone to expire, got %v", state)
+	}
+	tm.Put("key", 23, time.Second)
+	if value, state := tm.GetWithState("key"); state != Present || value != 23 {
+		t.Errorf("expected (23, Present), got (%d, %v)", value, state)
+	}
+}
+
+func TestTimedMapWaitForExpiration(t *testing.T) {
+	tm := New[string, int](10 * time.Millisecond)
+	defer tm.Stop()
+	tm.Put("key1", 19, 50*time.Millisecond)
+	tm.Put("key2", 23, time.Minute)
+	if err := tm.WaitForExpiration(context.Background(), "key1"); err != nil {
+		t.Errorf("expected nil error, got %v", err)
+	}
+	if tm.Contains("key1") {
+		t.Errorf("expected key1 to be expired")
+	}
+	go tm.Delete("key2")
+	if err := tm.WaitForExpiration(context.Background(), "key2"); err != nil {
+		t.Errorf("expected nil error, got %v", err)
+	}
+	tm.Put("key3", 29, time.Minute)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := tm.WaitForExpiration(ctx, "key3"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected context.DeadlineExceeded, got %v", err)
+	}
+	if len(tm.waiters) != 0 {
+		t.Errorf("expected the waiter to be released, got %d", len(tm.waiters))
+	}
+}
+
+func TestTimedMapExpirationChannel(t *testing.T) {
+	clock := newFakeClock()
+	tm := NewWithClock[int, int](0, clock)
+	ch := tm.ExpirationChannel()
+	tm.Put(1, 19, time.Second)
+	tm.Put(2, 23, time.Minute)
+	clock.Advance(time.Second)
+	tm.Get(1)
+	if key := <-ch; key != 1 {
+		t.Errorf("expected key 1, got %d", key)
+	}
+	for i := range ExpirationChannelSize + 1 {
+		tm.Put(i, i, time.Second)
+	}
+	clock.Advance(time.Second)
+	if removed := tm.CleanupNow(); removed != ExpirationChannelSize+1 {
+		t.Fatalf("expected %d entries to be removed, got %d", ExpirationChannelSize+1, removed)
+	}
+	if len(ch) != ExpirationChannelSize {
+		t.Errorf("expected the channel to be full, got %d keys", len(ch))
+	}
+}
+
+func TestTimedMapTouch(t *testing.T) {
+	clock := newFakeClock()
+	tm := NewWithClock[string, int](time.Minute, clock)
+	tm.Put("key", 19, 3*time.Second)
+	clock.Advance(time.Second)
+	remaining, ok := tm.Touch("key", 5*time.Second)
+	if !ok || remaining != 2*time.Second {
+		t.Errorf("expected (2s, true), got (%v, %t)", remaining, ok)
+	}
+	if ttl, _ := tm.TTL("key"); ttl != 5*time.Second {
+		t.Errorf("expected TTL of 5s, got %v", ttl)
+	}
+	tm.PutPermanent("permanent", 23)
+	if remaining, ok := tm.Touch("permanent", time.Second); !ok || remaining != NoExpiration {
+		t.Errorf("expected (NoExpiration, true), got (%v, %t)", remaining, ok)
+	}
+	if _, ok := tm.Touch("missing", time.Second); ok {
+		t.Errorf("expected missing key to not be touched")
+	}
+}
+
+func TestTimedMapString(t *testing.T) {
+	clock := newFakeClock()
+	tm := NewWithClock[string, int](time.Minute, clock)
+	tm.Put("key1", 19, 2*time.Second)
+	tm.Put("expired", 23, time.Second)
+	clock.Advance(time.Second)
+	if s := tm.String(); s != "TimedMap[1]{key1:19(1s)}" {
+		t.Errorf("expected TimedMap[1]{key1:19(1s)}, got %s", s)
+	}
+	for i := range 2 * StringLimit {
+		tm.PutPermanent(fmt.Sprint(i), i)
+	}
+	if s := tm.String(); !strings.HasPrefix(s, "TimedMap[21]{") || !strings.HasSuffix(s, " ...}") || strings.Count(s, ":") != StringLimit {
+		t.Errorf("expected %d of the 21 entries to be described, got %s", StringLimit, s)
+	}
+}
+
+func TestTimedMapReset(t *testing.T) {
+	tm := New[string, int](time.Minute)
+	defer tm.Stop()
+	expired := 0
+	tm.OnExpire(func(key string, value int) {
+		expired++
+	})
+	tm.Put("key1", 19, time.Minute)
+	tm.Put("key2", 23, -time.Second)
+	tm.Get("key1")
+	tm.Get("key2")
+	tm.Reset()
+	if tm.Size() != 0 {
+		t.Errorf("expected size 0, got %d", tm.Size())
+	}
+	if stats := tm.Stats(); stats != (Stats{}) {
+		t.Errorf("expected zeroed stats, got %+v", stats)
+	}
+	tm.Put("key3", 29, -time.Second)
+	tm.Get("key3")
+	if expired != 2 {
+		t.Errorf("expected the OnExpire callback to be kept, got %d calls", expired)
+	}
+}
+
+func TestTimedMapConcurrentGetExpired(t *testing.T) {
+	tm := NewLazy[int, int]()
+	var expired atomic.Int64
+	tm.OnExpire(func(key int, value int) {
+		expired.Add(1)
+	})
+	for i := range 100 {
+		tm.Put(i, i, -time.Second)
+	}
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 100 {
+				if _, ok := tm.Get(i); ok {
+					t.Errorf("expected key %d to be expired", i)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if tm.Size() != 0 {
+		t.Errorf("expected all expired entries to be removed, got size %d", tm.Size())
+	}
+	if expired.Load() != 100 {
+		t.Errorf("expected every entry to be reported once, got %d reports", expired.Load())
+	}
+}
+
+func TestTimedMapGetStale(t *testing.T) {
+	clock := newFakeClock()
+	tm := NewWithClock[string, int](0, clock)
+	tm.Put("key", 19, time.Second)
+	if value, expired, ok := tm.GetStale("key"); !ok || expired || value != 19 {
+		t.Errorf("expected (19, false, true), got (%d, %t, %t)", value, expired, ok)
+	}
+	clock.Advance(time.Second)
+	if value, expired, ok := tm.GetStale("key"); !ok || !expired || value != 19 {
+		t.Errorf("expected (19, true, true), got (%d, %t, %t)", value, expired, ok)
+	}
+	if tm.Size() != 1 {
+		t.Errorf("expected the stale entry to be kept, got size %d", tm.Size())
+	}
+	if _, _, ok := tm.GetStale("missing"); ok {
+		t.Errorf("expected missing key to not exist")
+	}
+	if stats := tm.Stats(); stats.Hits != 1 || stats.Misses != 2 {
+		t.Errorf("expected 1 hit and 2 misses, got %+v", stats)
+	}
+}
+
+func TestTimedMapPutIfAbsent(t *testing.T) {
+	tm := New[string, int](time.Minute)
+	defer tm.Stop()
+	if !tm.PutIfAbsent("key", 19, time.Minute) {
+		t.Errorf("expected value to be added")
+	}
+	if tm.PutIfAbsent("key", 23, time.Minute) {
+		t.Errorf("expected existing value to be kept")
+	}
+	if value, _ := tm.Get("key"); value != 19 {
+		t.Errorf("expected value 19, got %d", value)
+	}
+	tm.Put("expired", 29, -time.Second)
+	if !tm.PutIfAbsent("expired", 31, time.Minute) {
+		t.Errorf("expected expired value to be replaced")
+	}
+}
+
+func TestTimedMapPutIfPresent(t *testing.T) {
+	tm := New[string, int](time.Minute)
+	defer tm.Stop()
+	if tm.PutIfPresent("key", 19, time.Minute) || tm.Contains("key") {
+		t.Errorf("expected missing key to not be added")
+	}
+	tm.Put("key", 19, time.Second)
+	if !tm.PutIfPresent("key", 23, time.Minute) {
+		t.Errorf("expected existing value to be replaced")
+	}
+	if value, _ := tm.Get("key"); value != 23 {
+		t.Errorf("expected value 23, got %d", value)
+	}
+	if ttl, _ := tm.TTL("key"); ttl <= time.Second {
+		t.Errorf("expected time-to-live to be replaced, got %v", ttl)
+	}
+	tm.Put("expired", 29, -time.Second)
+	if tm.PutIfPresent("expired", 31, time.Minute) {
+		t.Errorf("expected expired key to not be brought back")
+	}
+}
+
+func TestTimedMapExpirationJitter(t *testing.T) {
+	ttls := func(seed uint64) []time.Duration {
+		clock := newFakeClock()
+		tm := NewWithClock[int, int](0, clock)
+		tm.SetExpirationJitter(0.5, rand.NewPCG(seed, seed))
+		ttls := make([]time.Duration, 100)
+		for i := range ttls {
+			tm.Put(i, i, 10*time.Second)
+			ttls[i], _ = tm.TTL(i)
+		}
+		return ttls
+	}
+	first := ttls(19)
+	for _, ttl := range first {
+		if ttl <= 5*time.Second || ttl > 10*time.Second {
+			t.Errorf("expected a TTL between 5s and 10s, got %v", ttl)
+		}
+	}
+	if slices.Min(first) == slices.Max(first) {
+		t.Errorf("expected the expiration times to be spread out")
+	}
+	if !slices.Equal(first, ttls(19)) {
+		t.Errorf("expected the same seed to yield the same expiration times")
+	}
+}
+
+// tickingClock is a [Clock] that moves forward by a fixed step every time it is read.
+type tickingClock struct {
+	fakeClock
+	step time.Duration
+}
+
+func (c *tickingClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(c.step)
+	return c.now
+}
+
+func TestTimedMapGetAllSingleNow(t *testing.T) {
+	clock := &tickingClock{fakeClock: fakeClock{now: time.Now()}}
+	tm := NewWithClock[int, int](0, clock)
+	keys := make([]int, 100)
+	for i := range keys {
+		keys[i] = i
+		tm.Put(i, i, time.Second)
+	}
+	clock.step = 10 * time.Millisecond
+	values := tm.GetAll(keys)
+	if len(values) != len(keys) {
+		t.Errorf("expected every key to be checked against the same time, got %d of %d keys", len(values), len(keys))
+	}
+}
+
+func TestTimedMapWeigher(t *testing.T) {
+	tm := NewWithWeigher(time.Minute, 10, func(key string, value []byte) int64 {
+		return int64(len(value))
+	})
+	defer tm.Stop()
+	var evicted []string
+	tm.OnEvict(func(key string, value []byte) {
+		evicted = append(evicted, key)
+	})
+	tm.Put("key1", make([]byte, 4), time.Minute)
+	tm.Put("key2", make([]byte, 4), time.Minute)
+	tm.Get("key1")
+	tm.Put("key3", make([]byte, 4), time.Minute)
+	if !slices.Equal(evicted, []string{"key2"}) {
+		t.Errorf("expected key2 to be evicted, got %v", evicted)
+	}
+	tm.Update("key3", func(old []byte) []byte {
+		return make([]byte, 8)
+	})
+	if !slices.Equal(evicted, []string{"key2", "key1"}) {
+		t.Errorf("expected key1 to be evicted after key3 grew, got %v", evicted)
+	}
+	tm.Put("heavy", make([]byte, 11), time.Minute)
+	if tm.Size() != 0 || tm.weight != 0 {
+		t.Errorf("expected an entry heavier than the maximum weight to evict everything, got size %d and weight %d", tm.Size(), tm.weight)
+	}
+}
+
+func TestTimedMapWeigherHeavyTombstone(t *testing.T) {
+	tm := NewWithWeigher(0, 3, func(key string, value []byte) int64 {
+		return int64(len(key))
+	})
+	var evicted []string
+	tm.OnEvict(func(key string, value []byte) {
+		evicted = append(evicted, key)
+	})
+	tm.PutTombstone("longkey", time.Minute)
+	if tm.Size() != 0 || len(evicted) != 0 {
+		t.Errorf("expected the heavy tombstone to be evicted silently, got size %d and evictions %v", tm.Size(), evicted)
+	}
+	if _, state := tm.GetWithState("longkey"); state != Missing {
+		t.Errorf("expected the evicted tombstone to be Missing, got %v", state)
+	}
+}
+
+func TestTimedMapExtendAll(t *testing.T) {
+	clock := newFakeClock()
+	tm := NewWithClock[string, int](0, clock)
+	tm.Put("key1", 19, time.Second)
+	tm.Put("key2", 23, 2*time.Second)
+	tm.PutPermanent("permanent", 29)
+	tm.Put("expired", 31, -time.Second)
+	if extended := tm.ExtendAll(time.Minute); extended != 2 {
+		t.Errorf("expected 2 entries to be extended, got %d", extended)
+	}
+	if ttl, _ := tm.TTL("key2"); ttl != time.Minute+2*time.Second {
+		t.Errorf("expected TTL of 1m2s, got %v", ttl)
+	}
+	clock.Advance(time.Minute)
+	if removed := tm.CleanupNow(); removed != 1 || tm.Size() != 3 {
+		t.Errorf("expected only the expired entry to be removed, got %d removed and size %d", removed, tm.Size())
+	}
+}
+
+func TestTimedMapDeleteExpired(t *testing.T) {
+	clock := newFakeClock()
+	tm := NewWithClock[string, int](0, clock)
+	tm.Put("key1", 19, 2*time.Second)
+	tm.Put("key2", 23, time.Second)
+	tm.Put("key3", 29, time.Minute)
+	clock.Advance(2 * time.Second)
+	removed := tm.DeleteExpired()
+	if len(removed) != 2 || removed[0].Key != "key2" || removed[0].Value != 23 || removed[1].Key != "key1" {
+		t.Errorf("expected key2 and key1 to be removed, got %v", removed)
+	}
+	if tm.Size() != 1 || !tm.Contains("key3") {
+		t.Errorf("expected only key3 to remain, got size %d", tm.Size())
+	}
+}
+
+func TestTimedMapDeleteExpiredSingleNow(t *testing.T) {
+	clock := &tickingClock{fakeClock: fakeClock{now: time.Now()}, step: time.Second}
+	tm := NewWithClock[string, int](0, clock)
+	tm.Put("key", 19, 3*time.Second)
+	// Every reading of the clock moves it forward, so the entry expires between two of them.
+	var removed []Entry[string, int]
+	for range 3 {
+		if removed = tm.DeleteExpired(); len(removed) > 0 {
+			break
+		}
+	}
+	if len(removed) != 1 || removed[0].TTL > 0 {
+		t.Errorf("expected the removed entry to be exported as expired, got %v", removed)
+	}
+}
+
+func TestTimedMapGetOrError(t *testing.T) {
+	clock := newFakeClock()
+	tm := NewWithClock[string, int](0, clock)
+	tm.Put("key1", 19, time.Minute)
+	tm.Put("key2", 23, time.Second)
+	tm.PutTombstone("key3", time.Minute)
+	clock.Advance(time.Second)
+	if value, err := tm.GetOrError("key1"); err != nil || value != 19 {
+		t.Errorf("expected value 19, got %d and %v", value, err)
+	}
+	if _, err := tm.GetOrError("key2"); !errors.Is(err, ErrExpired) {
+		t.Errorf("expected ErrExpired for key2, got %v", err)
+	}
+	if _, err := tm.GetOrError("key2"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound for the removed key2, got %v", err)
+	}
+	for _, key := range []string{"key3", "missing"} {
+		if _, err := tm.GetOrError(key); !errors.Is(err, ErrNotFound) {
+			t.Errorf("expected ErrNotFound for %s, got %v", key, err)
+		}
+	}
+	tm.Put("key4", 29, time.Second)
+	clock.Advance(time.Second)
+	if _, state := tm.GetWithState("key4"); state != Missing {
+		t.Errorf("expected the expired key4 to be Missing, got %v", state)
+	}
+	tm.PutTombstone("key5", time.Second)
+	clock.Advance(time.Second)
+	expirations := tm.Stats().Expirations
+	if _, err := tm.GetOrError("key5"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound for an expired tombstone, got %v", err)
+	}
+	if tm.Stats().Expirations != expirations {
+		t.Errorf("expected the expired tombstone not to count as an expiration")
+	}
+}
+
+func TestTimedMapDrain(t *testing.T) {
+	clock := newFakeClock()
+	tm := NewWithClock[string, int](0, clock)
+	var expired []string
+	tm.OnExpire(func(key string, value int) {
+		expired = append(expired, key)
+	})
+	tm.Put("key1", 19, time.Second)
+	tm.Put("key2", 23, time.Minute)
+	tm.PutPermanent("key3", 29)
+	tm.PutTombstone("key4", time.Minute)
+	clock.Advance(time.Second)
+	values := tm.Drain()
+	if !maps.Equal(values, map[string]int{"key2": 23, "key3": 29}) {
+		t.Errorf("expected key2 and key3 to be returned, got %v", values)
+	}
+	if !slices.Equal(expired, []string{"key1"}) {
+		t.Errorf("expected callback for key1, got %v", expired)
+	}
+	if tm.Size() != 3 {
+		t.Errorf("expected the remaining entries to be kept, got size %d", tm.Size())
+	}
+}
+
+func TestTimedMapUpdateEach(t *testing.T) {
+	clock := newFakeClock()
+	tm := NewWithClock[string, int](0, clock)
+	tm.Put("key1", 19, time.Second)
+	tm.Put("key2", 23, time.Second)
+	tm.Put("key3", 29, time.Second)
+	tm.UpdateEach(func(key string, value int, expiration time.Time) (time.Time, bool) {
+		switch value {
+		case 19:
+			return expiration.Add(time.Minute), true
+		case 23:
+			return time.Time{}, true
+		default:
+			return expiration, false
+		}
+	})
+	if ttl, _ := tm.TTL("key1"); ttl != time.Minute+time.Second {
+		t.Errorf("expected TTL of 1m1s, got %v", ttl)
+	}
+	if ttl, _ := tm.TTL("key2"); ttl != NoExpiration {
+		t.Errorf("expected key2 to never expire, got %v", ttl)
+	}
+	if tm.Contains("key3") {
+		t.Errorf("expected key3 to be removed")
+	}
+	clock.Advance(time.Minute)
+	if removed := tm.CleanupNow(); removed != 0 {
+		t.Errorf("expected no entry to expire, got %d", removed)
+	}
+}
+
+func TestTimedMapMaxTTL(t *testing.T) {
+	clock := newFakeClock()
+	tm := NewWithClock[string, int](0, clock)
+	tm.Put("key", 19, time.Duration(math.MaxInt64))
+	tm.ExtendAll(time.Duration(math.MaxInt64))
+	clock.Advance(100 * 365 * 24 * time.Hour)
+	tm.CleanupNow()
+	if value, ok := tm.Get("key"); !ok || value != 19 {
+		t.Errorf("expected the entry to survive, got (%d, %t)", value, ok)
+	}
+	if ttl, ok := tm.TTL("key"); !ok || ttl <= 0 {
+		t.Errorf("expected a positive TTL, got %v", ttl)
+	}
+}
+
+func TestTimedMapStopAndDrain(t *testing.T) {
+	tm := New[string, int](time.Minute)
+	var expired, evicted []string
+	tm.OnExpire(func(key string, value int) {
+		expired = append(expired, key)
+	})
+	tm.OnEvict(func(key string, value int) {
+		evicted = append(evicted, key)
+	})
+	tm.Put("key1", 19, time.Minute)
+	tm.PutPermanent("key2", 23)
+	tm.Put("expired", 29, -time.Second)
+	tm.StopAndDrain()
+	slices.Sort(evicted)
+	if !slices.Equal(evicted, []string{"key1", "key2"}) || !slices.Equal(expired, []string{"expired"}) {
+		t.Errorf("expected every entry to be drained, got evicted %v and expired %v", evicted, expired)
+	}
+	if tm.Size() != 0 {
+		t.Errorf("expected size 0, got %d", tm.Size())
+	}
+}
+
+func TestTimedMapStopAndDrainWaitsForCleanup(t *testing.T) {
+	tm := New[string, int](time.Millisecond)
+	sweeping, release := make(chan struct{}), make(chan struct{})
+	tm.OnSweep(func(removed []Entry[string, int]) {
+		if len(removed) > 0 {
+			close(sweeping)
+			<-release
+		}
+	})
+	tm.Put("expired", 19, -time.Second)
+	<-sweeping
+	drained := make(chan struct{})
+	go func() {
+		tm.StopAndDrain()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+		t.Fatalf("expected StopAndDrain to wait for the sweep callback")
+	case <-time.After(10 * time.Millisecond):
+	}
+	close(release)
+	<-drained
+	// A lazy map has no cleanup goroutine to wait for.
+	NewLazy[string, int]().StopAndDrain()
+}
+
+func TestTimedMapGetWithTTL(t *testing.T) {
+	clock := newFakeClock()
+	tm := NewWithClock[string, int](0, clock)
+	tm.Put("key", 19, 3*time.Second)
+	tm.PutPermanent("permanent", 23)
+	clock.Advance(time.Second)
+	if value, remaining, ok := tm.GetWithTTL("key"); !ok || value != 19 || remaining != 2*time.Second {
+		t.Errorf("expected (19, 2s, true), got (%d, %v, %t)", value, remaining, ok)
+	}
+	if _, remaining, ok := tm.GetWithTTL("permanent"); !ok || remaining != NoExpiration {
+		t.Errorf("expected (NoExpiration, true), got (%v, %t)", remaining, ok)
+	}
+	clock.Advance(2 * time.Second)
+	if _, _, ok := tm.GetWithTTL("key"); ok {
+		t.Errorf("expected key to be expired")
+	}
+}
+
+func TestTimedMapGetWithGrace(t *testing.T) {
+	clock := newFakeClock()
+	tm := NewWithClock[string, int](0, clock)
+	tm.Put("key", 19, time.Second)
+	if value, fresh, ok := tm.GetWithGrace("key", time.Second); !ok || !fresh || value != 19 {
+		t.Errorf("expected (19, true, true), got (%d, %t, %t)", value, fresh, ok)
+	}
+	clock.Advance(1500 * time.Millisecond)
+	if value, fresh, ok := tm.GetWithGrace("key", time.Second); !ok || fresh || value != 19 {
+		t.Errorf("expected (19, false, true), got (%d, %t, %t)", value, fresh, ok)
+	}
+	if tm.Size() != 1 {
+		t.Errorf("expected the entry to be kept within the grace period")
+	}
+	clock.Advance(500 * time.Millisecond)
+	if _, _, ok := tm.GetWithGrace("key", time.Second); ok {
+		t.Errorf("expected the entry to be gone after the grace period")
+	}
+	if tm.Size() != 0 {
+		t.Errorf("expected the entry to be removed after the grace period")
+	}
+}
+
+func TestCleanupStopsAfterStop(t *testing.T) {
+	tm := New[string, int](time.Millisecond)
+	tm.Stop()
+	select {
+	case <-tm.exited:
+	case <-time.After(time.Second):
+		t.Fatalf("expected the cleanup goroutine to return after Stop")
+	}
+	lazy := NewLazy[string, int]()
+	lazy.SetCleanupInterval(time.Millisecond)
+	lazy.Stop()
+	select {
+	case <-lazy.exited:
+	case <-time.After(time.Second):
+		t.Fatalf("expected a lazily started cleanup goroutine to return after Stop")
+	}
+}
+
+func TestTimedMapGetWithRefreshHint(t *testing.T) {
+	clock := newFakeClock()
+	tm := NewWithClock[string, int](0, clock)
+	tm.Put("key", 19, 10*time.Second)
+	tm.PutPermanent("permanent", 23)
+	if value, needsRefresh, ok := tm.GetWithRefreshHint("key", 5*time.Second); !ok || needsRefresh || value != 19 {
+		t.Errorf("expected (19, false, true), got (%d, %t, %t)", value, needsRefresh, ok)
+	}
+	clock.Advance(6 * time.Second)
+	if value, needsRefresh, ok := tm.GetWithRefreshHint("key", 5*time.Second); !ok || !needsRefresh || value != 19 {
+		t.Errorf("expected (19, true, true), got (%d, %t, %t)", value, needsRefresh, ok)
+	}
+	if _, needsRefresh, ok := tm.GetWithRefreshHint("permanent", 5*time.Second); !ok || needsRefresh {
+		t.Errorf("expected permanent entry to never need a refresh")
+	}
+}
+
+func TestTimedMapGetAndReset(t *testing.T) {
+	tm := New[string, int](time.Minute)
+	defer tm.Stop()
+	Increment(tm, "bucket", 19, time.Second)
+	if value, ok := tm.GetAndReset("bucket", 0, time.Minute); !ok || value != 19 {
+		t.Errorf("expected (19, true), got (%d, %t)", value, ok)
+	}
+	if value, _ := tm.Get("bucket"); value != 0 {
+		t.Errorf("expected value to be reset to 0, got %d", value)
+	}
+	if ttl, _ := tm.TTL("bucket"); ttl <= time.Second {
+		t.Errorf("expected a fresh TTL, got %v", ttl)
+	}
+	if _, ok := tm.GetAndReset("missing", 0, time.Minute); ok || tm.Contains("missing") {
+		t.Errorf("expected missing key to be left alone")
+	}
+}
+
+func TestTimedMapAge(t *testing.T) {
+	clock := newFakeClock()
+	tm := NewWithClock[string, int](0, clock)
+	tm.Put("key", 19, time.Minute)
+	clock.Advance(time.Second)
+	tm.Refresh("key", time.Minute)
+	clock.Advance(time.Second)
+	if age, ok := tm.Age("key"); !ok || age != 2*time.Second {
+		t.Errorf("expected (2s, true), got (%v, %t)", age, ok)
+	}
+	if age, _ := tm.Clone().Age("key"); age != 2*time.Second {
+		t.Errorf("expected the clone to keep the age, got %v", age)
+	}
+	tm.Put("key", 23, time.Minute)
+	if age, _ := tm.Age("key"); age != 0 {
+		t.Errorf("expected replacing the value to reset the age, got %v", age)
+	}
+	if _, ok := tm.Age("missing"); ok {
+		t.Errorf("expected missing key to have no age")
+	}
+}
+
+func TestTimedMapOnSweep(t *testing.T) {
+	clock := newFakeClock()
+	tm := NewWithClock[string, int](0, clock)
+	var sweeps [][]Entry[string, int]
+	tm.OnSweep(func(removed []Entry[string, int]) {
+		sweeps = append(sweeps, removed)
+	})
+	tm.Put("key1", 19, time.Second)
+	tm.Put("key2", 23, time.Second)
+	tm.Put("key3", 29, time.Minute)
+	tm.CleanupNow()
+	clock.Advance(time.Second)
+	tm.CleanupNow()
+	if len(sweeps) != 1 || len(sweeps[0]) != 2 {
+		t.Fatalf("expected a single call with 2 entries, got %v", sweeps)
+	}
+	keys := []string{sweeps[0][0].Key, sweeps[0][1].Key}
+	slices.Sort(keys)
+	if !slices.Equal(keys, []string{"key1", "key2"}) {
+		t.Errorf("expected key1 and key2 to be swept, got %v", keys)
+	}
+}
+
+func TestTimedMapClearWithCallback(t *testing.T) {
+	tm := New[string, int](time.Minute)
+	defer tm.Stop()
+	var evicted []string
+	tm.OnEvict(func(key string, value int) {
+		evicted = append(evicted, key)
+	})
+	tm.Put("key1", 19, time.Minute)
+	tm.Put("key2", 23, time.Minute)
+	tm.ClearWithCallback()
+	slices.Sort(evicted)
+	if !slices.Equal(evicted, []string{"key1", "key2"}) {
+		t.Errorf("expected key1 and key2 to be passed to OnEvict, got %v", evicted)
+	}
+	if tm.Size() != 0 {
+		t.Errorf("expected size 0, got %d", tm.Size())
+	}
+	if stats := tm.Stats(); stats.Evictions != 0 {
+		t.Errorf("expected cleared entries not to count as evictions, got %d", stats.Evictions)
+	}
+}
+
+func TestTimedMapInspect(t *testing.T) {
+	clock := newFakeClock()
+	tm := NewWithClock[string, int](0, clock)
+	insertedAt := clock.Now()
+	tm.Put("key", 19, 3*time.Second)
+	clock.Advance(time.Second)
+	tm.Get("key")
+	tm.Get("key")
+	info, ok := tm.Inspect("key")
+	expected := EntryInfo[string, int]{
+		Key:        "key",
+		Value:      19,
+		InsertedAt: insertedAt,
+		Expiration: insertedAt.Add(3 * time.Second),
+		TTL:        2 * time.Second,
+		Hits:       2,
+	}
+	if !ok || info != expected {
+		t.Errorf("expected %+v, got %+v", expected, info)
+	}
+	if _, ok := tm.Inspect("missing"); ok {
+		t.Errorf("expected missing key to not be inspected")
+	}
+}
+
+// steppedClock is a [Clock] reporting wall clock times only, as a system clock adjusted while the process runs would.
+type steppedClock struct {
+	fakeClock
+}
+
+func (c *steppedClock) Now() time.Time {
+	return c.fakeClock.Now().Round(0)
+}
+
+func TestTimedMapMonotonicClock(t *testing.T) {
+	tm := NewLazy[string, int]()
+	tm.Put("key", 19, time.Hour)
+	tm.Refresh("key", time.Hour)
+	for _, e := range []*entry[string, int]{tm.store["key"], tm.Clone().store["key"]} {
+		if !strings.Contains(e.expiration.String(), "m=") {
+			t.Errorf("expected the expiration time to keep the monotonic clock reading, got %v", e.expiration)
+		}
+	}
+	clock := &steppedClock{fakeClock: fakeClock{now: time.Now()}}
+	skewed := NewWithClock[string, int](0, clock)
+	skewed.Put("key", 19, time.Minute)
+	clock.Advance(-time.Hour)
+	clock.Advance(time.Hour + time.Minute - time.Second)
+	if !skewed.Contains("key") {
+		t.Errorf("expected the entry to follow the injected clock across a backward step")
+	}
+	clock.Advance(time.Second)
+	if skewed.Contains("key") {
+		t.Errorf("expected the entry to expire once the injected clock reaches its expiration time")
+	}
+}
+
+func TestTimedMapRenameKey(t *testing.T) {
+	clock := newFakeClock()
+	tm := NewWithClock[string, int](0, clock)
+	tm.Put("tmp", 19, 2*time.Second)
+	tm.Put("permanent", 23, time.Minute)
+	clock.Advance(time.Second)
+	if !tm.RenameKey("tmp", "permanent") {
+		t.Errorf("expected tmp to be renamed")
+	}
+	if tm.Contains("tmp") || tm.Size() != 1 {
+		t.Errorf("expected only the renamed entry to remain, got size %d", tm.Size())
+	}
+	if value, ttl, ok := tm.GetWithTTL("permanent"); !ok || value != 19 || ttl != time.Second {
+		t.Errorf("expected (19, 1s, true), got (%d, %v, %t)", value, ttl, ok)
+	}
+	if tm.RenameKey("missing", "other") {
+		t.Errorf("expected missing key to not be renamed")
+	}
+	clock.Advance(time.Second)
+	if tm.CleanupNow() != 1 || tm.Size() != 0 {
+		t.Errorf("expected the renamed entry to expire at its original time")
+	}
+}
@@ -0,0 +1,41 @@
+// Package timedmapexpvar publishes the statistics of a [timedmap.TimedMap] with [expvar].
+// It lives in its own package because importing expvar registers the /debug/vars handler on [net/http.DefaultServeMux],
+// which only the programs that use it should do.
+package timedmapexpvar
+
+import (
+	"expvar"
+
+	"github.com/mxmlkzdh/timedmap"
+)
+
+// stats is the JSON representation of a [timedmap.TimedMap] published by [Publish].
+type stats struct {
+	Size        int     `json:"size"`
+	Hits        uint64  `json:"hits"`
+	Misses      uint64  `json:"misses"`
+	HitRatio    float64 `json:"hit_ratio"`
+	Expirations uint64  `json:"expirations"`
+	Evictions   uint64  `json:"evictions"`
+}
+
+// Publish publishes the number of entries of tm that have not expired and the counters reported by
+// [timedmap.TimedMap.Stats], along with the hit ratio, as an [expvar.Var] with the given name, so that they
+// show up at /debug/vars. They are computed each time the variable is read, which counts the live entries with a full scan.
+// Like [expvar.Publish], it panics if a variable with the same name has already been published.
+func Publish[K comparable, V any](name string, tm *timedmap.TimedMap[K, V]) {
+	expvar.Publish(name, expvar.Func(func() any {
+		s := tm.Stats()
+		v := stats{
+			Size:        tm.LiveSize(),
+			Hits:        s.Hits,
+			Misses:      s.Misses,
+			Expirations: s.Expirations,
+			Evictions:   s.Evictions,
+		}
+		if lookups := s.Hits + s.Misses; lookups > 0 {
+			v.HitRatio = float64(s.Hits) / float64(lookups)
+		}
+		return v
+	}))
+}
@@ -0,0 +1,30 @@
+package timedmapexpvar
+
+import (
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mxmlkzdh/timedmap"
+)
+
+func TestPublish(t *testing.T) {
+	tm := timedmap.New[string, int](0)
+	// Variables cannot be unpublished, so each run of the test needs its own name.
+	name := fmt.Sprintf("TestPublish%d", time.Now().UnixNano())
+	Publish(name, tm)
+	tm.Put("key1", 19, time.Minute)
+	tm.Put("key2", 23, -time.Second)
+	tm.Get("key1")
+	tm.Get("missing")
+	var v stats
+	if err := json.Unmarshal([]byte(expvar.Get(name).String()), &v); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := stats{Size: 1, Hits: 1, Misses: 1, HitRatio: 0.5}
+	if v != want {
+		t.Errorf("expected %+v, got %+v", want, v)
+	}
+}
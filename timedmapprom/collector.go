@@ -0,0 +1,51 @@
+// Package timedmapprom exports the statistics of a [timedmap.TimedMap] to Prometheus.
+// It lives in its own module so that the Prometheus client is only required by the programs that use it.
+// The module is not published yet: it builds against the core module in the parent directory
+// until a tagged release of the core module can be required instead.
+package timedmapprom
+
+import (
+	"github.com/mxmlkzdh/timedmap"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// collector is the [prometheus.Collector] returned by [Collector].
+type collector[K comparable, V any] struct {
+	tm                                            *timedmap.TimedMap[K, V]
+	entries, hits, misses, expirations, evictions *prometheus.Desc
+}
+
+// Collector returns a [prometheus.Collector] reporting the number of entries of tm that have not expired as a gauge,
+// and the counters of [timedmap.TimedMap.Stats] as counters, all labeled with the given name so that the
+// collectors of several maps can be registered together. The values are read each time the collector is scraped,
+// which counts the live entries with a full scan.
+func Collector[K comparable, V any](tm *timedmap.TimedMap[K, V], name string) prometheus.Collector {
+	labels := prometheus.Labels{"name": name}
+	return &collector[K, V]{
+		tm:          tm,
+		entries:     prometheus.NewDesc("timedmap_entries", "Number of entries that have not expired.", nil, labels),
+		hits:        prometheus.NewDesc("timedmap_hits_total", "Number of lookups that found a value.", nil, labels),
+		misses:      prometheus.NewDesc("timedmap_misses_total", "Number of lookups that found no value.", nil, labels),
+		expirations: prometheus.NewDesc("timedmap_expirations_total", "Number of entries removed because they have expired.", nil, labels),
+		evictions:   prometheus.NewDesc("timedmap_evictions_total", "Number of entries evicted to respect the capacity or the maximum weight.", nil, labels),
+	}
+}
+
+// Describe implements [prometheus.Collector].
+func (c *collector[K, V]) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.entries
+	ch <- c.hits
+	ch <- c.misses
+	ch <- c.expirations
+	ch <- c.evictions
+}
+
+// Collect implements [prometheus.Collector].
+func (c *collector[K, V]) Collect(ch chan<- prometheus.Metric) {
+	stats := c.tm.Stats()
+	ch <- prometheus.MustNewConstMetric(c.entries, prometheus.GaugeValue, float64(c.tm.LiveSize()))
+	ch <- prometheus.MustNewConstMetric(c.hits, prometheus.CounterValue, float64(stats.Hits))
+	ch <- prometheus.MustNewConstMetric(c.misses, prometheus.CounterValue, float64(stats.Misses))
+	ch <- prometheus.MustNewConstMetric(c.expirations, prometheus.CounterValue, float64(stats.Expirations))
+	ch <- prometheus.MustNewConstMetric(c.evictions, prometheus.CounterValue, float64(stats.Evictions))
+}
@@ -0,0 +1,48 @@
+package timedmapprom
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mxmlkzdh/timedmap"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCollector(t *testing.T) {
+	tm := timedmap.NewWithCapacity[string, int](0, 1)
+	tm.Put("key1", 19, time.Minute)
+	tm.Put("key2", 23, time.Minute)
+	tm.Get("key2")
+	tm.Get("key1")
+	registry := prometheus.NewPedanticRegistry()
+	registry.MustRegister(Collector(tm, "sessions"))
+	registry.MustRegister(Collector(timedmap.New[string, int](0), "users"))
+	expected := `
+# HELP timedmap_entries Number of entries that have not expired.
+# TYPE timedmap_entries gauge
+timedmap_entries{name="sessions"} 1
+timedmap_entries{name="users"} 0
+# HELP timedmap_evictions_total Number of entries evicted to respect the capacity or the maximum weight.
+# TYPE timedmap_evictions_total counter
+timedmap_evictions_total{name="sessions"} 1
+timedmap_evictions_total{name="users"} 0
+# HELP timedmap_expirations_total Number of entries removed because they have expired.
+# TYPE timedmap_expirations_total counter
+timedmap_expirations_total{name="sessions"} 0
+timedmap_expirations_total{name="users"} 0
+# HELP timedmap_hits_total Number of lookups that found a value.
+# TYPE timedmap_hits_total counter
+timedmap_hits_total{name="sessions"} 1
+timedmap_hits_total{name="users"} 0
+# HELP timedmap_misses_total Number of lookups that found no value.
+# TYPE timedmap_misses_total counter
+timedmap_misses_total{name="sessions"} 1
+timedmap_misses_total{name="users"} 0
+`
+	names := []string{"timedmap_entries", "timedmap_evictions_total", "timedmap_expirations_total", "timedmap_hits_total", "timedmap_misses_total"}
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expected), names...); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,54 @@
+package timedmap
+
+import "time"
+
+// [Txn] gives access to the entries of a [TimedMap] during [TimedMap.Transaction].
+// Its methods operate directly on the entries, without locking, and must not be used once the transaction has returned.
+type Txn[K comparable, V any] struct {
+	tm *TimedMap[K, V]
+}
+
+// Transaction calls f while holding the write lock of the [TimedMap], so that the operations f performs
+// through the given [Txn] take effect atomically, as a whole, with respect to all other operations on the [TimedMap].
+// Calling any method of the [TimedMap] from f deadlocks. The callbacks for the entries that expired or were evicted
+// during the transaction are invoked once it has returned and the lock has been released.
+func (tm *TimedMap[K, V]) Transaction(f func(txn *Txn[K, V])) {
+	tm.mu.Lock()
+	defer tm.unlock()
+	f(&Txn[K, V]{tm: tm})
+}
+
+// Get returns the value associated with the given key and a boolean indicating if the key exists and has not expired.
+// An expired entry is removed, like [TimedMap.Get] does.
+func (txn *Txn[K, V]) Get(key K) (V, bool) {
+	var zero V
+	e, ok := txn.tm.store[key]
+	if !ok {
+		txn.tm.misses.Add(1)
+		return zero, false
+	}
+	if e.expired(txn.tm.clock.Now()) {
+		txn.tm.expire(e)
+		txn.tm.misses.Add(1)
+		return zero, false
+	}
+	if e.tombstone {
+		txn.tm.misses.Add(1)
+		return zero, false
+	}
+	txn.tm.hits.Add(1)
+	txn.tm.touch(e)
+	return e.value, true
+}
+
+// Put adds a value and its time-to-live duration for the given key, like [TimedMap.Put].
+func (txn *Txn[K, V]) Put(key K, value V, ttl time.Duration) {
+	txn.tm.set(key, value, txn.tm.expiresAt(txn.tm.clock.Now(), ttl))
+}
+
+// Delete removes the value associated with the given key regardless of its expiration time.
+func (txn *Txn[K, V]) Delete(key K) {
+	if e, ok := txn.tm.store[key]; ok {
+		txn.tm.remove(e)
+	}
+}
@@ -0,0 +1,39 @@
+package timedmap
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTimedMapTransaction(t *testing.T) {
+	tm := New[string, int](time.Minute)
+	defer tm.Stop()
+	tm.Put("from", 100, time.Minute)
+	tm.Put("to", 0, time.Minute)
+	var wg sync.WaitGroup
+	for range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tm.Transaction(func(txn *Txn[string, int]) {
+				from, _ := txn.Get("from")
+				to, _ := txn.Get("to")
+				txn.Put("from", from-1, time.Minute)
+				txn.Put("to", to+1, time.Minute)
+			})
+		}()
+	}
+	wg.Wait()
+	from, _ := tm.Get("from")
+	to, _ := tm.Get("to")
+	if from != 50 || to != 50 {
+		t.Errorf("expected (50, 50), got (%d, %d)", from, to)
+	}
+	tm.Transaction(func(txn *Txn[string, int]) {
+		txn.Delete("from")
+		if _, ok := txn.Get("from"); ok {
+			t.Errorf("expected from to be deleted within the transaction")
+		}
+	})
+}